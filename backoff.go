@@ -0,0 +1,68 @@
+package wxpay
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff is the policy of delays between retries or polls, the delay grow from Initial by Multiplier
+// up to Max, and is randomized by Jitter so that clients do not retry in lockstep
+type Backoff struct {
+	// Initial is the delay after the first attempt
+	Initial time.Duration
+	// Max cap the delay, zero means no cap
+	Max time.Duration
+	// Multiplier grow the delay after each attempt, values less than 1 are treated as 1(constant delay)
+	Multiplier float64
+	// Jitter randomize the delay by the fraction in both directions, e.g. 0.2 gives 80%~120% of the delay
+	Jitter float64
+	// MaxAttempts limit the attempts including the first one, zero means no limit
+	MaxAttempts int
+}
+
+var (
+	// BackoffFastQuery is for querying the result of a request just made, like pending payment or reverse
+	BackoffFastQuery = Backoff{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2, Jitter: 0.2, MaxAttempts: 10}
+	// BackoffSlowReconcile is for background work like reprocessing notifications or bills not ready yet
+	BackoffSlowReconcile = Backoff{Initial: time.Minute, Max: time.Hour, Multiplier: 2, Jitter: 0.2}
+)
+
+// Allow tell whether the attempt(the first is 1) is allowed by MaxAttempts
+func (this Backoff) Allow(attempt int) bool {
+	return this.MaxAttempts <= 0 || attempt <= this.MaxAttempts
+}
+
+// Delay return the delay after the attempt(the first is 1) before the next one
+func (this Backoff) Delay(attempt int) time.Duration {
+	delay := float64(this.Initial)
+	if this.Multiplier > 1 {
+		for i := 1; i < attempt; i++ {
+			delay *= this.Multiplier
+			if this.Max > 0 && delay >= float64(this.Max) {
+				break
+			}
+		}
+	}
+	if this.Max > 0 && delay > float64(this.Max) {
+		delay = float64(this.Max)
+	}
+	if this.Jitter > 0 {
+		delay *= 1 - this.Jitter + 2*this.Jitter*rand.Float64()
+	}
+
+	return time.Duration(delay)
+}
+
+// Wait sleep the delay after the attempt, it return the error of ctx if ctx is done before
+func (this Backoff) Wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(this.Delay(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,72 @@
+package wxpay
+
+import (
+	"strings"
+)
+
+// bankNames map the bank part of bank_type to bank name, bank_type is like "ICBC_DEBIT" or "CMB_CREDIT".
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=4_2
+var bankNames = map[string]string{
+	"ICBC":  "工商银行",
+	"ABC":   "农业银行",
+	"BOC":   "中国银行",
+	"CCB":   "建设银行",
+	"COMM":  "交通银行",
+	"PSBC":  "邮储银行",
+	"CMB":   "招商银行",
+	"CMBC":  "民生银行",
+	"CITIC": "中信银行",
+	"CEB":   "光大银行",
+	"CIB":   "兴业银行",
+	"SPDB":  "浦发银行",
+	"GDB":   "广发银行",
+	"PAB":   "平安银行",
+	"HXB":   "华夏银行",
+	"BOB":   "北京银行",
+	"BOSH":  "上海银行",
+	"NBCB":  "宁波银行",
+	"BJRCB": "北京农商行",
+	"SRCB":  "上海农商银行",
+	"HZB":   "杭州银行",
+	"JSB":   "江苏银行",
+	"CZB":   "浙商银行",
+	"CBHB":  "渤海银行",
+	"HSB":   "徽商银行",
+	"EGB":   "恒丰银行",
+}
+
+// specialBankTypes is the bank_type not in form of BANK_CARDTYPE
+var specialBankTypes = map[string]string{
+	"CFT":    "零钱",
+	"LQT":    "零钱通",
+	"OTHERS": "其他银行",
+}
+
+var cardTypeNames = map[string]string{
+	"DEBIT":  "借记卡",
+	"CREDIT": "信用卡",
+}
+
+// BankName return the human readable name of bank_type, e.g. "招商银行信用卡" for "CMB_CREDIT".
+// The code is returned as is if it is unknown.
+func BankName(bankType string) string {
+	if name, ok := specialBankTypes[bankType]; ok {
+		return name
+	}
+
+	i := strings.LastIndex(bankType, "_")
+	if i < 0 {
+		return bankType
+	}
+
+	bank, ok := bankNames[bankType[:i]]
+	if !ok {
+		return bankType
+	}
+	cardType, ok := cardTypeNames[bankType[i+1:]]
+	if !ok {
+		return bankType
+	}
+
+	return bank + cardType
+}
@@ -0,0 +1,179 @@
+package wxpay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+// BillCharset is the charset of legacy bills which are not in UTF-8
+const BillCharset = "GBK"
+
+// ErrNoBillExist is returned when there is no bill of the date, e.g. no trade is made on the day
+var ErrNoBillExist = errors.New("no bill exist")
+
+// isNoBillExist tell whether the error response of bill download means there is no bill of the date
+func isNoBillExist(resp map[string]string) bool {
+	return resp["error_code"] == "20002" || strings.EqualFold(resp["return_msg"], "No Bill Exist")
+}
+
+// BillType select the orders included in the transaction bill
+type BillType string
+
+const (
+	// BillTypeAll include all orders except the recharge refunds
+	BillTypeAll BillType = "ALL"
+	// BillTypeSuccess include paid orders
+	BillTypeSuccess BillType = "SUCCESS"
+	// BillTypeRefund include refunded orders
+	BillTypeRefund BillType = "REFUND"
+	// BillTypeRechargeRefund include refunds of recharged coupons
+	BillTypeRechargeRefund BillType = "RECHARGE_REFUND"
+)
+
+// BillQuery is the parameters of transaction bill download
+type BillQuery struct {
+	// Date of the bill in form of yyyyMMdd, the bill of a day is available after 10 am of the next day
+	Date     string
+	BillType BillType
+	// Gzip request the bill compressed, it is decompressed transparently
+	Gzip bool
+}
+
+// Bill is the downloaded bill in text, lines are separated by "\n" and
+// fields are separated by "," with a leading "`" in data rows
+type Bill struct {
+	Data []byte
+	// Envelope is the transport level details of the download response
+	Envelope *Envelope
+}
+
+// Reader return the reader of the raw bill text
+func (this *Bill) Reader() io.Reader {
+	return bytes.NewReader(this.Data)
+}
+
+// Table split the bill into header, data rows and summary, see ParseBillTable
+func (this *Bill) Table() *BillTable {
+	return ParseBillTable(this.Data)
+}
+
+// BillTable is the bill split into fields, the leading "`" of fields is removed
+type BillTable struct {
+	Header        []string
+	Rows          [][]string
+	SummaryHeader []string
+	Summary       []string
+	// Err is the error of converting the bill to UTF-8, the table is left empty if it is set
+	Err error
+}
+
+// ParseBillTable split the bill text, the first line without "`" is the header of rows,
+// the second is the header of summary, which is the last row.
+// Bill not in UTF-8 is converted from BillCharset with the package level CharsetReader if it is set,
+// bills downloaded by AppTrans are already converted with WxConfig.CharsetReader.
+// If the conversion failed, the error is recorded in Err instead of parsing the raw bytes.
+func ParseBillTable(data []byte) *BillTable {
+	table := &BillTable{}
+	data, err := decodeBillText(data, CharsetReader)
+	if err != nil {
+		table.Err = err
+		return table
+	}
+	text := strings.TrimPrefix(string(data), "\uFEFF")
+
+	var rows [][]string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "`") {
+			if table.Header == nil {
+				table.Header = strings.Split(line, ",")
+			} else {
+				table.SummaryHeader = strings.Split(line, ",")
+			}
+			continue
+		}
+
+		fields := splitBillLine(line)
+		if table.SummaryHeader != nil {
+			table.Summary = fields
+		} else {
+			rows = append(rows, fields)
+		}
+	}
+	table.Rows = rows
+
+	return table
+}
+
+// splitBillLine split a data row of bill, fields may contain "," so the row is split by ",`"
+func splitBillLine(line string) []string {
+	return strings.Split(strings.TrimPrefix(line, "`"), ",`")
+}
+
+// DownloadBill download the gzipped transaction bill of date(yyyyMMdd) and billType.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=9_6&index=8
+func (this *AppTrans) DownloadBill(date string, billType BillType) (*Bill, error) {
+	return this.DownloadBillByQuery(&BillQuery{Date: date, BillType: billType, Gzip: true})
+}
+
+// DownloadBillByQuery download the transaction bill, error response in xml is returned as error,
+// ErrNoBillExist if there is no bill of the date
+func (this *AppTrans) DownloadBillByQuery(query *BillQuery) (*Bill, error) {
+	param := this.newParam()
+	param["bill_date"] = query.Date
+	param["bill_type"] = string(query.BillType)
+	if query.Gzip {
+		param["tar_type"] = "GZIP"
+	}
+
+	data, envelope, err := this.callText(context.Background(), Endpoints[PathDownloadBill], param)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err = gunzipIfNeeded(data); err != nil {
+		return nil, err
+	}
+	if data, err = decodeBillText(data, this.charsetReader()); err != nil {
+		return nil, err
+	}
+	return &Bill{Data: data, Envelope: envelope}, nil
+}
+
+// decodeBillText convert the bill from BillCharset to UTF-8 with charsetReader if it is not in UTF-8
+func decodeBillText(data []byte, charsetReader func(string, io.Reader) (io.Reader, error)) ([]byte, error) {
+	if utf8.Valid(data) {
+		return data, nil
+	}
+
+	reader, err := charsetReaderOrError(charsetReader)(BillCharset, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(reader)
+}
+
+// gunzipIfNeeded decompress data if it starts with the gzip magic number
+func gunzipIfNeeded(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
@@ -0,0 +1,174 @@
+package wxpay
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testBill = "\uFEFF交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类," +
+	"应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注\r\n" +
+	"`2024-01-02 10:20:30,`wx1,`100,`0,`WEB,`4200001,`T1,`openid1,`JSAPI,`SUCCESS,`CMB_DEBIT,`CNY," +
+	"`1.00,`0.00,`0,`0,`0.00,`0.00,`,`,`Tom, Jerry,`attach,`0.00600,`0.60%,`1.00,`0.00,`\r\n" +
+	"`2024-01-02 11:00:00,`wx1,`100,`0,`WEB,`4200001,`T1,`openid1,`JSAPI,`REFUND,`CMB_DEBIT,`CNY," +
+	"`0.00,`0.00,`5000001,`R1,`0.50,`0.00,`ORIGINAL,`SUCCESS,`Tom, Jerry,`attach,`-0.00300,`0.60%,`0.00,`0.50,`\r\n" +
+	"总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\r\n" +
+	"`2,`1.00,`0.50,`0.00,`0.00300,`1.00,`0.50\r\n"
+
+func TestParseBillTable(t *testing.T) {
+	table := ParseBillTable([]byte(testBill))
+	if len(table.Header) != 27 || table.Header[0] != "交易时间" {
+		t.Fatalf("unexpected header: %v", table.Header)
+	}
+	if len(table.Rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(table.Rows))
+	}
+	if body := table.Rows[0][20]; body != "Tom, Jerry" {
+		t.Errorf("field with comma is split: %q", body)
+	}
+	if len(table.Summary) != 7 || table.Summary[0] != "2" {
+		t.Errorf("unexpected summary: %v", table.Summary)
+	}
+}
+
+func TestParseBillTableCharsetError(t *testing.T) {
+	// 交易时间 in GBK, it can not be converted without CharsetReader
+	gbk := []byte("\xbd\xbb\xd2\xd7\xca\xb1\xbc\xe4\r\n`2024-01-02 10:00:00\r\n")
+	table := ParseBillTable(gbk)
+	if table.Err == nil || table.Header != nil || table.Rows != nil {
+		t.Fatalf("raw bytes are parsed: %+v", table)
+	}
+	if _, _, err := ParseBillRecords(table); err != table.Err {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, _, err := ParseFundFlow(table); err != table.Err {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseBillRecords(t *testing.T) {
+	records, summary, err := ParseBillRecords(ParseBillTable([]byte(testBill)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	paid, refund := records[0], records[1]
+	if paid.OutTradeNo != "T1" || paid.SettlementTotalFee != 100 || paid.PoundageFee != 600 || paid.PoundageFee.Fen() != 1 {
+		t.Errorf("unexpected paid record: %+v", paid)
+	}
+	if paid.TradeTime.Format(BillTimeLayout) != "2024-01-02 10:20:30" || paid.TradeTime.Location() != ChinaTimeZone {
+		t.Errorf("unexpected trade time: %v", paid.TradeTime)
+	}
+	if refund.OutRefundNo != "R1" || refund.RefundFee != 50 || refund.PoundageFee != -300 {
+		t.Errorf("unexpected refund record: %+v", refund)
+	}
+	if summary.TotalCount != 2 || summary.SettlementTotalFee != 100 || summary.RefundFee != 50 || summary.PoundageFee != 300 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestBillReader(t *testing.T) {
+	reader := NewBillReader(context.Background(), strings.NewReader(testBill))
+	var records []*BillRecord
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 2 || records[1].OutRefundNo != "R1" {
+		t.Errorf("unexpected records: %v", records)
+	}
+	if summary := reader.Summary(); summary == nil || summary.TotalCount != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestBillReaderCharset(t *testing.T) {
+	// the fake charset reader replace the invalid bytes standing for GBK text
+	gbk := []byte("\xff\xfe")
+	trans := &AppTrans{Config: &WxConfig{CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(bytes.ReplaceAll(data, gbk, []byte("Tom"))), nil
+	}}}
+	bill := strings.Replace(testBill, "Tom", string(gbk), -1)
+
+	if _, err := NewBillReader(context.Background(), strings.NewReader(bill)).Read(); err == nil {
+		t.Error("want error for bill not in UTF-8 without CharsetReader")
+	}
+	record, err := trans.NewBillReader(context.Background(), strings.NewReader(bill)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Body != "Tom, Jerry" {
+		t.Errorf("got body %q", record.Body)
+	}
+}
+
+func TestDiffBills(t *testing.T) {
+	a, _, err := ParseBillRecords(ParseBillTable([]byte(testBill)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := append([]BillRecord(nil), a...)
+	b[0].SettlementTotalFee = 200
+	b = b[:1]
+	b = append(b, BillRecord{TransactionId: "4200002", OutTradeNo: "T2"})
+
+	diffs := DiffBills(a, b)
+	if len(diffs) != 3 {
+		t.Fatalf("got %d diffs: %+v", len(diffs), diffs)
+	}
+	if diffs[0].Type != BillDiffChanged || diffs[0].Key != "4200001" || len(diffs[0].Fields) != 1 || diffs[0].Fields[0] != "SettlementTotalFee" {
+		t.Errorf("unexpected diff: %+v", diffs[0])
+	}
+	if diffs[1].Type != BillDiffRemoved || diffs[1].Key != "4200001/5000001" {
+		t.Errorf("unexpected diff: %+v", diffs[1])
+	}
+	if diffs[2].Type != BillDiffAdded || diffs[2].B.OutTradeNo != "T2" {
+		t.Errorf("unexpected diff: %+v", diffs[2])
+	}
+	if diffs := DiffBills(a, a); len(diffs) != 0 {
+		t.Errorf("same bills differ: %+v", diffs)
+	}
+}
+
+func TestDownloadBillsRange(t *testing.T) {
+	trans := newTestGateway(t, func(path string, param map[string]string) string {
+		if param["bill_date"] == "20240102" {
+			return "<xml><return_code>FAIL</return_code><return_msg>No Bill Exist</return_msg><error_code>20002</error_code></xml>"
+		}
+		return testBill
+	})
+
+	bills, err := trans.DownloadBillsRange("20231231", "20240103", BillTypeAll, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bills.NoBillDates) != 1 || bills.NoBillDates[0] != "20240102" {
+		t.Errorf("unexpected dates without bill: %v", bills.NoBillDates)
+	}
+	if len(bills.Records) != 6 || bills.Records[0].BillDate != "20231231" || bills.Records[5].BillDate != "20240103" {
+		t.Errorf("unexpected records: %+v", bills.Records)
+	}
+	if len(bills.Summaries) != 3 {
+		t.Errorf("got %d summaries", len(bills.Summaries))
+	}
+
+	if _, err := trans.DownloadBillsRange("20240103", "20240101", BillTypeAll, 1); err == nil {
+		t.Error("want error for reversed range")
+	}
+}
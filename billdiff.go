@@ -0,0 +1,91 @@
+package wxpay
+
+import (
+	"reflect"
+	"sort"
+)
+
+// BillDiffType is the kind of difference between two bill datasets
+type BillDiffType string
+
+const (
+	// BillDiffAdded means the record is only in the second dataset
+	BillDiffAdded BillDiffType = "ADDED"
+	// BillDiffRemoved means the record is only in the first dataset
+	BillDiffRemoved BillDiffType = "REMOVED"
+	// BillDiffChanged means the record is in both datasets with different fields
+	BillDiffChanged BillDiffType = "CHANGED"
+)
+
+// BillDiff is a difference of a record, A or B is nil if the record is missing in that dataset
+type BillDiff struct {
+	Type BillDiffType
+	// Key is transaction_id of payment rows, or transaction_id and refund_id joined by "/" of refund rows
+	Key string
+	A   *BillRecord
+	B   *BillRecord
+	// Fields is the names of BillRecord fields differ, only for BillDiffChanged
+	Fields []string
+}
+
+// DiffBills compare two datasets of bill records, e.g. a re-download and the archived copy of the same day,
+// and report the added, removed and changed records sorted by key. Records are matched by transaction_id
+// and refund_id, a duplicated key in one dataset is compared with the last record of the key.
+func DiffBills(a, b []BillRecord) []BillDiff {
+	recordsA, recordsB := billRecordsByKey(a), billRecordsByKey(b)
+
+	var diffs []BillDiff
+	for key, recordA := range recordsA {
+		recordB, ok := recordsB[key]
+		if !ok {
+			diffs = append(diffs, BillDiff{Type: BillDiffRemoved, Key: key, A: recordA})
+			continue
+		}
+		if fields := billRecordFieldsDiffer(recordA, recordB); len(fields) > 0 {
+			diffs = append(diffs, BillDiff{Type: BillDiffChanged, Key: key, A: recordA, B: recordB, Fields: fields})
+		}
+	}
+	for key, recordB := range recordsB {
+		if _, ok := recordsA[key]; !ok {
+			diffs = append(diffs, BillDiff{Type: BillDiffAdded, Key: key, B: recordB})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs
+}
+
+// billRecordKey return the key matching the record across datasets
+func billRecordKey(record *BillRecord) string {
+	if record.IsRefund() {
+		return record.TransactionId + "/" + record.RefundId
+	}
+	return record.TransactionId
+}
+
+func billRecordsByKey(records []BillRecord) map[string]*BillRecord {
+	byKey := make(map[string]*BillRecord)
+	for i := range records {
+		byKey[billRecordKey(&records[i])] = &records[i]
+	}
+	return byKey
+}
+
+// billRecordFieldsDiffer return the names of fields differ between the records
+func billRecordFieldsDiffer(a, b *BillRecord) []string {
+	var fields []string
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		name := va.Type().Field(i).Name
+		if name == "TradeTime" {
+			if !a.TradeTime.Equal(b.TradeTime) {
+				fields = append(fields, name)
+			}
+			continue
+		}
+		if va.Field(i).Interface() != vb.Field(i).Interface() {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
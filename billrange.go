@@ -0,0 +1,126 @@
+package wxpay
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BillDateLayout is the layout of bill date, e.g. 20240102
+const BillDateLayout = "20060102"
+
+// DatedBillRecord is the bill record with the date of bill it comes from
+type DatedBillRecord struct {
+	BillDate string
+	BillRecord
+}
+
+// BillRange is the bills of a range of dates
+type BillRange struct {
+	// Records is the records of all dates, ordered by date and then by the order in bill
+	Records []DatedBillRecord
+	// Summaries is the summary of each date with bill
+	Summaries map[string]*BillSummary
+	// NoBillDates is the dates without bill in order, e.g. days without trade
+	NoBillDates []string
+}
+
+// dailyBill is the downloaded and parsed bill of a date
+type dailyBill struct {
+	records []BillRecord
+	summary *BillSummary
+	noBill  bool
+	err     error
+}
+
+// DownloadBillsRange download and parse the bills of billType from date "from" to "to"(yyyyMMdd, inclusive),
+// with at most concurrency downloads at once(less than 1 is treated as 1). Dates without bill are skipped
+// and listed in NoBillDates. Once a download fail, the dates not started are not downloaded and the error
+// is returned with its date.
+func (this *AppTrans) DownloadBillsRange(from, to string, billType BillType, concurrency int) (*BillRange, error) {
+	dates, err := billDates(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	bills := make([]dailyBill, len(dates))
+	var mu sync.Mutex
+	failed := false
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, date := range dates {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, date string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			bill := &bills[i]
+			downloaded, err := this.DownloadBill(date, billType)
+			if err == ErrNoBillExist {
+				bill.noBill = true
+				return
+			}
+			if err == nil {
+				bill.records, bill.summary, err = downloaded.Records()
+			}
+			if err != nil {
+				bill.err = fmt.Errorf("bill of %s: %v", date, err)
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(i, date)
+	}
+	wg.Wait()
+
+	result := &BillRange{Summaries: make(map[string]*BillSummary)}
+	for i, bill := range bills {
+		if bill.err != nil {
+			return nil, bill.err
+		}
+		if bill.noBill {
+			result.NoBillDates = append(result.NoBillDates, dates[i])
+			continue
+		}
+		if bill.summary != nil {
+			result.Summaries[dates[i]] = bill.summary
+		}
+		for _, record := range bill.records {
+			result.Records = append(result.Records, DatedBillRecord{BillDate: dates[i], BillRecord: record})
+		}
+	}
+	return result, nil
+}
+
+// billDates list the dates from "from" to "to" inclusive
+func billDates(from, to string) ([]string, error) {
+	start, err := time.ParseInLocation(BillDateLayout, from, ChinaTimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bill date:%s", from)
+	}
+	end, err := time.ParseInLocation(BillDateLayout, to, ChinaTimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bill date:%s", to)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("bill date %s is before %s", to, from)
+	}
+
+	var dates []string
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day.Format(BillDateLayout))
+	}
+	return dates, nil
+}
@@ -0,0 +1,99 @@
+package wxpay
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxBillLineLength is the max length of a line in bill accepted by BillReader
+const maxBillLineLength = 1 << 20
+
+// BillReader read the records of transaction bill one at a time from io.Reader,
+// for bills too large to be loaded into memory with ParseBillRecords
+type BillReader struct {
+	ctx           context.Context
+	scanner       *bufio.Scanner
+	line          int
+	header        []string
+	summaryHeader []string
+	summary       *BillSummary
+	charsetReader func(string, io.Reader) (io.Reader, error)
+}
+
+// NewBillReader return BillReader reading the bill text from r, e.g. Bill.Reader() or a decompressed file.
+// Reading stops with the error of ctx once ctx is done. Lines not in UTF-8 are converted from BillCharset
+// with the package level CharsetReader, use AppTrans.NewBillReader for WxConfig.CharsetReader.
+func NewBillReader(ctx context.Context, r io.Reader) *BillReader {
+	return newBillReader(ctx, r, CharsetReader)
+}
+
+// NewBillReader is like NewBillReader of package but convert lines with the charset reader of config
+func (this *AppTrans) NewBillReader(ctx context.Context, r io.Reader) *BillReader {
+	return newBillReader(ctx, r, this.charsetReader())
+}
+
+func newBillReader(ctx context.Context, r io.Reader, charsetReader func(string, io.Reader) (io.Reader, error)) *BillReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxBillLineLength)
+	return &BillReader{ctx: ctx, scanner: scanner, charsetReader: charsetReader}
+}
+
+// Read return the next record, io.EOF is returned after the last one, and Summary is available then
+func (this *BillReader) Read() (*BillRecord, error) {
+	for this.scanner.Scan() {
+		if err := this.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		this.line++
+		data, err := decodeBillText(this.scanner.Bytes(), this.charsetReader)
+		if err != nil {
+			return nil, fmt.Errorf("bill line %d: %v", this.line, err)
+		}
+		line := strings.TrimRight(string(data), "\r")
+		if this.line == 1 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "`") {
+			if this.header == nil {
+				this.header = strings.Split(line, ",")
+			} else {
+				this.summaryHeader = strings.Split(line, ",")
+			}
+			continue
+		}
+
+		fields := splitBillLine(line)
+		if this.summaryHeader != nil {
+			summary, err := parseBillSummary(this.summaryHeader, fields)
+			if err != nil {
+				return nil, fmt.Errorf("bill line %d: %v", this.line, err)
+			}
+			this.summary = summary
+			continue
+		}
+
+		record, err := parseBillRecord(this.header, fields)
+		if err != nil {
+			return nil, fmt.Errorf("bill line %d: %v", this.line, err)
+		}
+		return &record, nil
+	}
+
+	if err := this.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Summary return the total row of the bill, it is nil until all records are read
+func (this *BillReader) Summary() *BillSummary {
+	return this.summary
+}
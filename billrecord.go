@@ -0,0 +1,183 @@
+package wxpay
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// BillTimeLayout is the layout of trade time in bills, in ChinaTimeZone
+const BillTimeLayout = "2006-01-02 15:04:05"
+
+// BillRecord is a data row of transaction bill, amounts are parsed from yuan.
+// PoundageFee is kept in Poundage for its five decimals.
+// Columns not in the bill of the bill type(e.g. refund fields of SUCCESS bill) are left empty.
+type BillRecord struct {
+	TradeTime     time.Time
+	AppId         string
+	MchId         string
+	SubMchId      string
+	DeviceInfo    string
+	TransactionId string
+	OutTradeNo    string
+	OpenId        string
+	TradeType     string
+	TradeState    string
+	BankType      string
+	FeeType       string
+	// SettlementTotalFee is the order amount minus non-recharge coupons(应结订单金额)
+	SettlementTotalFee Fen
+	CouponFee          Fen
+	RefundId           string
+	OutRefundNo        string
+	// RefundFee is the refunded amount minus non-recharge coupons(退款金额)
+	RefundFee       Fen
+	CouponRefundFee Fen
+	RefundType      string
+	RefundStatus    string
+	Body            string
+	Attach          string
+	// PoundageFee has five decimals in yuan, use PoundageFee.Fen() for the amount in fen
+	PoundageFee Poundage
+	// Rate is the poundage rate like "0.60%"
+	Rate           string
+	TotalFee       Fen
+	ApplyRefundFee Fen
+	RateRemark     string
+}
+
+// IsRefund tell whether the record is a refund row, payment rows have refund_id "0" in bills
+func (this *BillRecord) IsRefund() bool {
+	return this.TradeState == "REFUND" || (this.RefundId != "" && this.RefundId != "0")
+}
+
+// BillSummary is the total row of transaction bill
+type BillSummary struct {
+	TotalCount         int
+	SettlementTotalFee Fen
+	RefundFee          Fen
+	CouponRefundFee    Fen
+	PoundageFee        Poundage
+	TotalFee           Fen
+	ApplyRefundFee     Fen
+}
+
+// billColumns map the column names in header of bill to the setter of BillRecord,
+// some columns are renamed across bill versions
+var billColumns = map[string]func(*BillRecord, string) error{
+	"交易时间": func(r *BillRecord, v string) (err error) {
+		r.TradeTime, err = time.ParseInLocation(BillTimeLayout, v, ChinaTimeZone)
+		return err
+	},
+	"公众账号ID":     func(r *BillRecord, v string) error { r.AppId = v; return nil },
+	"商户号":        func(r *BillRecord, v string) error { r.MchId = v; return nil },
+	"特约商户号":      func(r *BillRecord, v string) error { r.SubMchId = v; return nil },
+	"子商户号":       func(r *BillRecord, v string) error { r.SubMchId = v; return nil },
+	"设备号":        func(r *BillRecord, v string) error { r.DeviceInfo = v; return nil },
+	"微信订单号":      func(r *BillRecord, v string) error { r.TransactionId = v; return nil },
+	"商户订单号":      func(r *BillRecord, v string) error { r.OutTradeNo = v; return nil },
+	"用户标识":       func(r *BillRecord, v string) error { r.OpenId = v; return nil },
+	"交易类型":       func(r *BillRecord, v string) error { r.TradeType = v; return nil },
+	"交易状态":       func(r *BillRecord, v string) error { r.TradeState = v; return nil },
+	"付款银行":       func(r *BillRecord, v string) error { r.BankType = v; return nil },
+	"货币种类":       func(r *BillRecord, v string) error { r.FeeType = v; return nil },
+	"应结订单金额":     func(r *BillRecord, v string) (err error) { r.SettlementTotalFee, err = ParseYuan(v); return err },
+	"总金额":        func(r *BillRecord, v string) (err error) { r.SettlementTotalFee, err = ParseYuan(v); return err },
+	"代金券金额":      func(r *BillRecord, v string) (err error) { r.CouponFee, err = ParseYuan(v); return err },
+	"代金券或立减优惠金额": func(r *BillRecord, v string) (err error) { r.CouponFee, err = ParseYuan(v); return err },
+	"微信退款单号":     func(r *BillRecord, v string) error { r.RefundId = v; return nil },
+	"商户退款单号":     func(r *BillRecord, v string) error { r.OutRefundNo = v; return nil },
+	"退款金额":       func(r *BillRecord, v string) (err error) { r.RefundFee, err = ParseYuan(v); return err },
+	"充值券退款金额":    func(r *BillRecord, v string) (err error) { r.CouponRefundFee, err = ParseYuan(v); return err },
+	"代金券或立减优惠退款金额": func(r *BillRecord, v string) (err error) {
+		r.CouponRefundFee, err = ParseYuan(v)
+		return err
+	},
+	"退款类型":   func(r *BillRecord, v string) error { r.RefundType = v; return nil },
+	"退款状态":   func(r *BillRecord, v string) error { r.RefundStatus = v; return nil },
+	"商品名称":   func(r *BillRecord, v string) error { r.Body = v; return nil },
+	"商户数据包":  func(r *BillRecord, v string) error { r.Attach = v; return nil },
+	"手续费":    func(r *BillRecord, v string) (err error) { r.PoundageFee, err = ParsePoundage(v); return err },
+	"费率":     func(r *BillRecord, v string) error { r.Rate = v; return nil },
+	"订单金额":   func(r *BillRecord, v string) (err error) { r.TotalFee, err = ParseYuan(v); return err },
+	"申请退款金额": func(r *BillRecord, v string) (err error) { r.ApplyRefundFee, err = ParseYuan(v); return err },
+	"费率备注":   func(r *BillRecord, v string) error { r.RateRemark = v; return nil },
+}
+
+// billSummaryColumns map the column names in summary header to the setter of BillSummary
+var billSummaryColumns = map[string]func(*BillSummary, string) error{
+	"总交易单数":    func(s *BillSummary, v string) (err error) { s.TotalCount, err = strconv.Atoi(v); return err },
+	"应结订单总金额":  func(s *BillSummary, v string) (err error) { s.SettlementTotalFee, err = ParseYuan(v); return err },
+	"总交易额":     func(s *BillSummary, v string) (err error) { s.SettlementTotalFee, err = ParseYuan(v); return err },
+	"退款总金额":    func(s *BillSummary, v string) (err error) { s.RefundFee, err = ParseYuan(v); return err },
+	"总退款金额":    func(s *BillSummary, v string) (err error) { s.RefundFee, err = ParseYuan(v); return err },
+	"充值券退款总金额": func(s *BillSummary, v string) (err error) { s.CouponRefundFee, err = ParseYuan(v); return err },
+	"手续费总金额":   func(s *BillSummary, v string) (err error) { s.PoundageFee, err = ParsePoundage(v); return err },
+	"订单总金额":    func(s *BillSummary, v string) (err error) { s.TotalFee, err = ParseYuan(v); return err },
+	"申请退款总金额":  func(s *BillSummary, v string) (err error) { s.ApplyRefundFee, err = ParseYuan(v); return err },
+}
+
+// Records parse the data rows and summary of the bill, see ParseBillRecords
+func (this *Bill) Records() ([]BillRecord, *BillSummary, error) {
+	return ParseBillRecords(this.Table())
+}
+
+// ParseBillRecords convert the split bill into typed records and summary,
+// columns are located by the names in header, unknown columns are ignored. BillTable.Err is returned if set
+func ParseBillRecords(table *BillTable) ([]BillRecord, *BillSummary, error) {
+	if table.Err != nil {
+		return nil, nil, table.Err
+	}
+
+	var records []BillRecord
+	for i, fields := range table.Rows {
+		record, err := parseBillRecord(table.Header, fields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bill row %d: %v", i+1, err)
+		}
+		records = append(records, record)
+	}
+
+	summary, err := parseBillSummary(table.SummaryHeader, table.Summary)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return records, summary, nil
+}
+
+// parseBillRecord convert a data row of bill with the header into BillRecord
+func parseBillRecord(header []string, fields []string) (BillRecord, error) {
+	record := BillRecord{}
+	if len(fields) != len(header) {
+		return record, fmt.Errorf("%d fields, the header has %d", len(fields), len(header))
+	}
+
+	for i, name := range header {
+		if set, ok := billColumns[name]; ok {
+			if err := set(&record, fields[i]); err != nil {
+				return record, fmt.Errorf("column %s: %v", name, err)
+			}
+		}
+	}
+
+	return record, nil
+}
+
+// parseBillSummary convert the summary row of bill with its header into BillSummary
+func parseBillSummary(header []string, fields []string) (*BillSummary, error) {
+	summary := &BillSummary{}
+	if len(fields) != len(header) {
+		return nil, fmt.Errorf("bill summary has %d fields, the header has %d", len(fields), len(header))
+	}
+
+	for i, name := range header {
+		if set, ok := billSummaryColumns[name]; ok {
+			if err := set(summary, fields[i]); err != nil {
+				return nil, fmt.Errorf("bill summary column %s: %v", name, err)
+			}
+		}
+	}
+
+	return summary, nil
+}
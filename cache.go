@@ -0,0 +1,52 @@
+package wxpay
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache is a key-value cache with ttl, implement it with redis or memcache to share between instances
+type Cache interface {
+	Get(key string) (string, bool)
+	Set(key, value string, ttl time.Duration)
+}
+
+// MemoryCache is a Cache in process memory, expired entries are dropped on Set
+type MemoryCache struct {
+	mu    sync.Mutex
+	items map[string]cacheItem
+}
+
+type cacheItem struct {
+	value  string
+	expire time.Time
+}
+
+// NewMemoryCache return an empty MemoryCache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{items: make(map[string]cacheItem)}
+}
+
+func (this *MemoryCache) Get(key string) (string, bool) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	item, ok := this.items[key]
+	if !ok || time.Now().After(item.expire) {
+		return "", false
+	}
+	return item.value, true
+}
+
+func (this *MemoryCache) Set(key, value string, ttl time.Duration) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	now := time.Now()
+	for k, item := range this.items {
+		if now.After(item.expire) {
+			delete(this.items, k)
+		}
+	}
+	this.items[key] = cacheItem{value: value, expire: now.Add(ttl)}
+}
@@ -0,0 +1,49 @@
+package wxpay
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// httpClient return the http client for requests without merchant certificate, limited by WxConfig.Timeout
+func (this *AppTrans) httpClient() *http.Client {
+	return &http.Client{Timeout: this.timeout()}
+}
+
+// timeout return WxConfig.Timeout, or DefaultTimeout if it is not set
+func (this *AppTrans) timeout() time.Duration {
+	if this.Config.Timeout > 0 {
+		return this.Config.Timeout
+	}
+	return DefaultTimeout
+}
+
+// certClient return the http client presenting merchant certificate, which is loaded from
+// WxConfig.CertFile and WxConfig.KeyFile on first success. A failed load is tried again on next
+// call, so the certificate can be fixed without restart.
+func (this *AppTrans) certClient() (*http.Client, error) {
+	this.certMu.Lock()
+	defer this.certMu.Unlock()
+
+	if this.certHttp != nil {
+		return this.certHttp, nil
+	}
+	if this.Config.CertFile == "" || this.Config.KeyFile == "" {
+		return nil, errors.New("merchant certificate is required, set CertFile and KeyFile in config")
+	}
+
+	cert, err := tls.LoadX509KeyPair(this.Config.CertFile, this.Config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	this.certHttp = &http.Client{
+		Timeout: this.timeout(),
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		},
+	}
+	return this.certHttp, nil
+}
@@ -0,0 +1,45 @@
+package wxpay
+
+import (
+	"context"
+	"encoding/xml"
+)
+
+// CloseOrderResult represent close order response message from weixin pay.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=9_3&index=5
+type CloseOrderResult struct {
+	XMLName     xml.Name `xml:"xml"`
+	ReturnCode  string   `xml:"return_code"`
+	ReturnMsg   string   `xml:"return_msg"`
+	AppId       string   `xml:"appid"`
+	MchId       string   `xml:"mch_id"`
+	NonceStr    string   `xml:"nonce_str"`
+	Sign        string   `xml:"sign"`
+	ResultCode  string   `xml:"result_code"`
+	ResultMsg   string   `xml:"result_msg"`
+	ErrCode     string   `xml:"err_code"`
+	ErrCodeDesc string   `xml:"err_code_des"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// CloseOrder close the unpaid order by out_trade_no, so the order can be submitted again with
+// a new out_trade_no. An order can only be closed 5 minutes after it is placed.
+func (this *AppTrans) CloseOrder(outTradeNo string) (*CloseOrderResult, error) {
+	param := this.newParam()
+	param["out_trade_no"] = outTradeNo
+
+	resp, respInMap, envelope, err := this.call(context.Background(), Endpoints[PathCloseOrder], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	closeOrderResult := CloseOrderResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &closeOrderResult); err != nil {
+		return nil, err
+	}
+	return &closeOrderResult, nil
+}
@@ -0,0 +1,84 @@
+package wxpay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Comment is a rating of order given by user
+type Comment struct {
+	Time          string
+	TransactionId string
+	Stars         int
+	Content       string
+}
+
+// CommentResult is a page of comments, Offset should be used as offset of next page
+type CommentResult struct {
+	Offset   int
+	Comments []Comment
+}
+
+// QueryComments pull the comments of orders between beginTime and endTime in form of yyyyMMddHHmmss(批量查询订单评价),
+// at most limit(max 200) comments from offset are returned. It is signed with HMAC-SHA256 and merchant
+// certificate is required.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=9_17&index=11
+func (this *AppTrans) QueryComments(beginTime, endTime string, offset, limit int) (*CommentResult, error) {
+	param := this.newParam()
+	param["begin_time"] = beginTime
+	param["end_time"] = endTime
+	param["offset"] = strconv.Itoa(offset)
+	param["limit"] = strconv.Itoa(limit)
+
+	resp, _, err := this.callText(context.Background(), Endpoints[PathBatchQueryComment], param)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseComments(resp)
+}
+
+// parseComments parse the comments in text, the first line is offset and each following line is
+// a comment like "`2017-07-01 10:00:05,`1001690740201411100005734289,`5,`great"
+func parseComments(data []byte) (*CommentResult, error) {
+	result := &CommentResult{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() {
+		return result, scanner.Err()
+	}
+	offset, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid offset line: %s", scanner.Text())
+	}
+	result.Offset = offset
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(strings.TrimPrefix(line, "`"), ",`", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid comment line: %s", line)
+		}
+		stars, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid comment stars: %s", line)
+		}
+
+		result.Comments = append(result.Comments, Comment{
+			Time:          fields[0],
+			TransactionId: fields[1],
+			Stars:         stars,
+			Content:       fields[3],
+		})
+	}
+
+	return result, scanner.Err()
+}
@@ -1,5 +1,19 @@
 package wxpay
 
+import (
+	"io"
+	"time"
+)
+
+// DefaultClockSkewThreshold is the default of WxConfig.ClockSkewThreshold
+const DefaultClockSkewThreshold = time.Minute
+
+// DefaultTimeout is the default of WxConfig.Timeout
+const DefaultTimeout = 30 * time.Second
+
+// DefaultApiHost is the host of weixin pay api
+const DefaultApiHost = "https://api.mch.weixin.qq.com"
+
 type WxConfig struct {
 	AppId         string
 	AppKey        string
@@ -7,5 +21,88 @@ type WxConfig struct {
 	NotifyUrl     string
 	PlaceOrderUrl string
 	QueryOrderUrl string
-	TradeType     string
+	TradeType     TradeType
+
+	// AppSecret is the secret of official account of AppId, it is only required by ExchangeCodeForOpenId
+	AppSecret string
+
+	// Secrets provide AppKey on demand instead of the AppKey field when it is set
+	Secrets SecretProvider
+
+	// Sandbox send all requests to the sandbox(仿真测试系统) of weixin pay by inserting /sandboxnew into
+	// the path of urls, and sign them with the sandbox sign key, which is fetched with AppKey on first use
+	Sandbox bool
+
+	// ApiHost is the host of apis other than place order and query order, default DefaultApiHost
+	ApiHost string
+
+	// CertFile and KeyFile is the merchant certificate and private key in PEM format(apiclient_cert.pem
+	// and apiclient_key.pem), they are required by apis like refund
+	CertFile string
+	KeyFile  string
+
+	// Timeout limit each http request to weixin pay including reading the response, DefaultTimeout if zero.
+	// The request is also canceled when its ctx is done.
+	Timeout time.Duration
+
+	// SignType is SignTypeMD5 or SignTypeHmacSha256 used for requests and responses, default MD5
+	SignType string
+
+	// OnClockSkew is called with the local time minus server time when it exceed ClockSkewThreshold
+	// (default DefaultClockSkewThreshold), the server time is from Date header of responses.
+	// Skewed clock makes time sensitive checks like timestamps and time_expire fail silently.
+	OnClockSkew        func(skew time.Duration)
+	ClockSkewThreshold time.Duration
+
+	// OnResponse is called with the url and the http status and headers of every response from weixin pay,
+	// before the body is checked. Responses with status other than 200 fail with *HttpStatusError.
+	OnResponse func(targetUrl string, envelope *Envelope)
+
+	// MaxInFlight limit the concurrent requests to weixin pay of the merchant, zero means no limit.
+	// The limit is shared by all AppTrans of the same MchId in the process.
+	// At most MaxQueued requests wait for a slot, others fail with *OverloadedError.
+	MaxInFlight int
+	MaxQueued   int
+
+	// OpenIdPrefix is the common prefix of openids issued for AppId(openids of one appid share the
+	// first few characters, e.g. the first 6), JSAPI orders with openid not of this prefix are
+	// rejected before submission. Empty disable the check.
+	OpenIdPrefix string
+	// StrictOpenId reject JSAPI orders with openid not of OpenIdLength, by default only its characters are checked
+	StrictOpenId bool
+
+	// Journal record the attempts of money-moving calls like refund and transfer, nil disable it
+	Journal Journal
+
+	// AuthCodeCache cache the openid of auth_code for AuthCodeToOpenId, nil disable the cache
+	AuthCodeCache Cache
+
+	// ReplayGuard check the notifications in NotifyHandler after their sign is verified, nil disable it.
+	// Duplicates are answered with SUCCESS without calling the handle, notifications out of its window
+	// are answered with FAIL.
+	ReplayGuard *ReplayGuard
+
+	// AcceptNotifyMerchant decide whether the notification for appid and mch_id is accepted, for
+	// services handling multiple merchants. By default only AppId and MchId of this config is accepted.
+	AcceptNotifyMerchant func(appId, mchId string) bool
+
+	// Logger report problems like panic in notification handlers, default to the standard logger
+	Logger Logger
+
+	// DisableStrictSign let responses without sign pass the verification,
+	// by default they are rejected
+	DisableStrictSign bool
+
+	// OnSignFailure is called when sign of response or notification is missing or not match, e.g. to count
+	// it as metrics. A spike usually means wrong key after rotation or messages being tampered.
+	OnSignFailure func(failure SignFailure)
+
+	// DiagnoseSign attach *SignDiagnosis to *SignError when sign does not match, for integration debugging.
+	// The diagnosis contains field values, do not enable it in production unless needed.
+	DiagnoseSign bool
+
+	// CharsetReader convert non UTF-8 responses and bills to UTF-8, e.g. the legacy ones in GBK.
+	// Standard library has no GBK support, set it to golang.org/x/net/html/charset.NewReaderLabel or similar.
+	// The package level CharsetReader is used if it is nil.
+	CharsetReader func(charset string, input io.Reader) (io.Reader, error)
 }
@@ -0,0 +1,48 @@
+package wxpay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// ConfirmPayment query the order by out_trade_no and decide whether it is paid with the expected total fee.
+// The success reported by app or page can be forged, use it before delivering the goods.
+// It return false with nil error if the order is not paid(yet), and error if the order
+// does not match the expectation or the query fail.
+func (this *AppTrans) ConfirmPayment(ctx context.Context, outTradeNo string, totalFee int64) (bool, error) {
+	result, err := this.queryOrder(ctx, map[string]string{"out_trade_no": outTradeNo})
+	if err != nil {
+		return false, err
+	}
+
+	if result.ReturnCode != "SUCCESS" {
+		return false, fmt.Errorf("return code:%s, return desc:%s", result.ReturnCode, result.ReturnMsg)
+	}
+
+	if result.ResultCode != "SUCCESS" {
+		if result.ErrCode == "ORDERNOTEXIST" {
+			return false, nil
+		}
+		return false, fmt.Errorf("result code:%s, result desc:%s", result.ErrCode, result.ErrCodeDesc)
+	}
+
+	if result.AppId != this.Config.AppId || result.MchId != this.Config.MchId {
+		return false, fmt.Errorf("merchant not match, want:%s/%s, got:%s/%s",
+			this.Config.AppId, this.Config.MchId, result.AppId, result.MchId)
+	}
+
+	if result.OrderId != outTradeNo {
+		return false, fmt.Errorf("out_trade_no not match, want:%s, got:%s", outTradeNo, result.OrderId)
+	}
+
+	if result.TradeState != "SUCCESS" {
+		return false, nil
+	}
+
+	if want := strconv.FormatInt(totalFee, 10); result.TotalFee != want {
+		return false, fmt.Errorf("total fee not match, want:%s, got:%s", want, result.TotalFee)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,123 @@
+package wxpay
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// RateScale is the scale of exchange rate returned by weixin pay, e.g. rate 646055000 means 6.46055000
+const RateScale = 100000000
+
+// currencyExponents list the currencies whose minor unit is not 1/100
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"IDR": 0,
+	"CLP": 0,
+	"KWD": 3,
+	"BHD": 3,
+	"JOD": 3,
+	"OMR": 3,
+}
+
+// MinorUnitExponent return the number of decimals of the minor unit of currency in ISO 4217 code,
+// amounts like total_fee are integers in this unit. Unknown currencies are treated as 2.
+func MinorUnitExponent(feeType string) int {
+	if exp, ok := currencyExponents[feeType]; ok {
+		return exp
+	}
+	return 2
+}
+
+// ConvertToCny convert amount in minor unit of feeType to CNY fen with the rate from weixin pay
+// (scaled by RateScale, the CNY value of 1 unit of feeType), rounded half away from zero.
+// Error is returned if rate is not positive, e.g. omitted by weixin pay, or the result overflow int64.
+func ConvertToCny(amount int64, feeType string, rate int64) (int64, error) {
+	if rate <= 0 {
+		return 0, fmt.Errorf("invalid exchange rate:%d", rate)
+	}
+	num := new(big.Int).Mul(big.NewInt(amount), big.NewInt(rate))
+	num.Mul(num, pow10(2))
+	den := new(big.Int).Mul(big.NewInt(RateScale), pow10(MinorUnitExponent(feeType)))
+	return roundDiv(num, den)
+}
+
+// ConvertFromCny convert CNY fen to minor unit of feeType with the rate from weixin pay,
+// rounded half away from zero. It is the reverse of ConvertToCny.
+func ConvertFromCny(fen int64, feeType string, rate int64) (int64, error) {
+	if rate <= 0 {
+		return 0, fmt.Errorf("invalid exchange rate:%d", rate)
+	}
+	num := new(big.Int).Mul(big.NewInt(fen), big.NewInt(RateScale))
+	num.Mul(num, pow10(MinorUnitExponent(feeType)))
+	den := new(big.Int).Mul(big.NewInt(rate), pow10(2))
+	return roundDiv(num, den)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundDiv return num/den rounded half away from zero, den must be positive
+func roundDiv(num, den *big.Int) (int64, error) {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	rem.Abs(rem).Mul(rem, big.NewInt(2))
+	if rem.Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("converted amount %s overflows int64", quo)
+	}
+	return quo.Int64(), nil
+}
+
+// CurrencyAmount is an amount in minor unit of FeeType(ISO 4217 code), e.g. cents of USD or yen of JPY,
+// for amounts not in CNY like the settlement of cross-border merchants
+type CurrencyAmount struct {
+	Amount  int64
+	FeeType string
+}
+
+// ParseCurrencyAmount parse the integer amount in minor unit of feeType, empty string is 0
+func ParseCurrencyAmount(s string, feeType string) (CurrencyAmount, error) {
+	amount := CurrencyAmount{FeeType: feeType}
+	if s == "" {
+		return amount, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return amount, fmt.Errorf("invalid amount:%s", s)
+	}
+	amount.Amount = n
+	return amount, nil
+}
+
+// Add return the sum of amounts, error is returned if they are in different currencies
+func (this CurrencyAmount) Add(other CurrencyAmount) (CurrencyAmount, error) {
+	if this.FeeType != other.FeeType {
+		return this, fmt.Errorf("cannot add %s to %s", other.FeeType, this.FeeType)
+	}
+	return CurrencyAmount{Amount: this.Amount + other.Amount, FeeType: this.FeeType}, nil
+}
+
+// String format the amount in major unit with its currency, e.g. "12.34 USD" or "1234 JPY"
+func (this CurrencyAmount) String() string {
+	exp := MinorUnitExponent(this.FeeType)
+	sign := ""
+	n := this.Amount
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	if exp == 0 {
+		return fmt.Sprintf("%s%d %s", sign, n, this.FeeType)
+	}
+	unit := pow10(exp).Int64()
+	return fmt.Sprintf("%s%d.%0*d %s", sign, n/unit, exp, n%unit, this.FeeType)
+}
@@ -0,0 +1,90 @@
+package wxpay
+
+// Paths of weixin pay apis on ApiHost
+const (
+	PathUnifiedOrder      = "/pay/unifiedorder"
+	PathOrderQuery        = "/pay/orderquery"
+	PathCloseOrder        = "/pay/closeorder"
+	PathMicropay          = "/pay/micropay"
+	PathReverse           = "/secapi/pay/reverse"
+	PathRefund            = "/secapi/pay/refund"
+	PathRefundQuery       = "/pay/refundquery"
+	PathDownloadBill      = "/pay/downloadbill"
+	PathDownloadFundFlow  = "/pay/downloadfundflow"
+	PathSettlementQuery   = "/pay/settlementquery"
+	PathQueryExchangeRate = "/pay/queryexchagerate"
+	PathAuthCodeToOpenId  = "/tools/authcodetoopenid"
+	PathBatchQueryComment = "/billcommentsp/batchquerycomment"
+	PathSandboxSignKey    = "/sandboxnew/pay/getsignkey"
+
+	PathTransfers        = "/mmpaymkttransfers/promotion/transfers"
+	PathGetTransferInfo  = "/mmpaymkttransfers/gettransferinfo"
+	PathPayBank          = "/mmpaysptrans/pay_bank"
+	PathQueryBank        = "/mmpaysptrans/query_bank"
+	PathGetPublicKey     = "/risk/getpublickey"
+	PathSendRedPack      = "/mmpaymkttransfers/sendredpack"
+	PathSendGroupRedPack = "/mmpaymkttransfers/sendgroupredpack"
+	PathGetRedPackInfo   = "/mmpaymkttransfers/gethbinfo"
+	PathWorkWxTransfer   = "/mmpaymkttransfers/promotion/paywwsptrans2pocket"
+	PathWorkWxRedPack    = "/mmpaymkttransfers/sendworkwxredpack"
+
+	PathProfitSharing               = "/secapi/pay/profitsharing"
+	PathMultiProfitSharing          = "/secapi/pay/multiprofitsharing"
+	PathProfitSharingQuery          = "/pay/profitsharingquery"
+	PathProfitSharingAddReceiver    = "/pay/profitsharingaddreceiver"
+	PathProfitSharingRemoveReceiver = "/pay/profitsharingremovereceiver"
+	PathProfitSharingFinish         = "/secapi/pay/profitsharingfinish"
+)
+
+// Endpoint describe how an api is called
+type Endpoint struct {
+	Path   string
+	Method string
+	// Cert tell whether the merchant certificate is required
+	Cert bool
+	// SignType is the sign type required by the api, empty means WxConfig.SignType is used
+	SignType string
+	// Unsigned tell the response carries no sign, so it is not verified
+	Unsigned bool
+	// Host is the host of api not on WxConfig.ApiHost, e.g. RiskApiHost
+	Host string
+}
+
+// RiskApiHost is the host of risk control apis like getting the public key for bank transfer
+const RiskApiHost = "https://fraud.mch.weixin.qq.com"
+
+// Endpoints is the catalog of known apis by path
+var Endpoints = map[string]Endpoint{
+	PathUnifiedOrder:      {Path: PathUnifiedOrder, Method: "POST"},
+	PathOrderQuery:        {Path: PathOrderQuery, Method: "POST"},
+	PathCloseOrder:        {Path: PathCloseOrder, Method: "POST"},
+	PathMicropay:          {Path: PathMicropay, Method: "POST"},
+	PathReverse:           {Path: PathReverse, Method: "POST", Cert: true},
+	PathRefund:            {Path: PathRefund, Method: "POST", Cert: true},
+	PathRefundQuery:       {Path: PathRefundQuery, Method: "POST"},
+	PathDownloadBill:      {Path: PathDownloadBill, Method: "POST", Unsigned: true},
+	PathDownloadFundFlow:  {Path: PathDownloadFundFlow, Method: "POST", Cert: true, SignType: SignTypeHmacSha256, Unsigned: true},
+	PathSettlementQuery:   {Path: PathSettlementQuery, Method: "POST"},
+	PathQueryExchangeRate: {Path: PathQueryExchangeRate, Method: "POST"},
+	PathAuthCodeToOpenId:  {Path: PathAuthCodeToOpenId, Method: "POST"},
+	PathBatchQueryComment: {Path: PathBatchQueryComment, Method: "POST", Cert: true, SignType: SignTypeHmacSha256, Unsigned: true},
+	PathSandboxSignKey:    {Path: PathSandboxSignKey, Method: "POST", Unsigned: true},
+
+	PathTransfers:        {Path: PathTransfers, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true},
+	PathGetTransferInfo:  {Path: PathGetTransferInfo, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true},
+	PathPayBank:          {Path: PathPayBank, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true},
+	PathQueryBank:        {Path: PathQueryBank, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true},
+	PathGetPublicKey:     {Path: PathGetPublicKey, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true, Host: RiskApiHost},
+	PathSendRedPack:      {Path: PathSendRedPack, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true},
+	PathSendGroupRedPack: {Path: PathSendGroupRedPack, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true},
+	PathGetRedPackInfo:   {Path: PathGetRedPackInfo, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true},
+	PathWorkWxTransfer:   {Path: PathWorkWxTransfer, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true},
+	PathWorkWxRedPack:    {Path: PathWorkWxRedPack, Method: "POST", Cert: true, SignType: SignTypeMD5, Unsigned: true},
+
+	PathProfitSharing:               {Path: PathProfitSharing, Method: "POST", Cert: true, SignType: SignTypeHmacSha256},
+	PathMultiProfitSharing:          {Path: PathMultiProfitSharing, Method: "POST", Cert: true, SignType: SignTypeHmacSha256},
+	PathProfitSharingQuery:          {Path: PathProfitSharingQuery, Method: "POST", SignType: SignTypeHmacSha256},
+	PathProfitSharingAddReceiver:    {Path: PathProfitSharingAddReceiver, Method: "POST", SignType: SignTypeHmacSha256},
+	PathProfitSharingRemoveReceiver: {Path: PathProfitSharingRemoveReceiver, Method: "POST", SignType: SignTypeHmacSha256},
+	PathProfitSharingFinish:         {Path: PathProfitSharingFinish, Method: "POST", Cert: true, SignType: SignTypeHmacSha256},
+}
@@ -0,0 +1,46 @@
+package wxpay
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Envelope is the transport level details of a response from weixin pay
+type Envelope struct {
+	StatusCode int
+	Header     http.Header
+	// Environment is the environment of the client which received the response
+	Environment Environment
+}
+
+// RequestId return the Request-ID header, give it to weixin pay when asking for support
+func (this *Envelope) RequestId() string {
+	return this.Header.Get("Request-ID")
+}
+
+// RetryAfter return the delay in Retry-After header, zero if it is absent or malformed
+func (this *Envelope) RetryAfter() time.Duration {
+	value := this.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// HttpStatusError is returned when weixin pay respond http status other than 200
+type HttpStatusError struct {
+	Envelope
+	Body []byte
+}
+
+func (this *HttpStatusError) Error() string {
+	return fmt.Sprintf("http status:%d, request id:%s", this.StatusCode, this.RequestId())
+}
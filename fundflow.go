@@ -0,0 +1,133 @@
+package wxpay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// FundAccountType is the account of fund flow bill
+type FundAccountType string
+
+const (
+	// FundAccountBasic is the basic account(基本账户)
+	FundAccountBasic FundAccountType = "Basic"
+	// FundAccountOperation is the operation account(运营账户)
+	FundAccountOperation FundAccountType = "Operation"
+	// FundAccountFees is the fees account(手续费账户)
+	FundAccountFees FundAccountType = "Fees"
+)
+
+// FundFlowRecord is a data row of fund flow bill, amounts are parsed from yuan
+type FundFlowRecord struct {
+	BookTime   time.Time
+	BizOrderNo string
+	FlowNo     string
+	BizName    string
+	BizType    string
+	// InOut is 收入 or 支出
+	InOut     string
+	Amount    Fen
+	Balance   Fen
+	Applicant string
+	Remark    string
+	VoucherNo string
+}
+
+// FundFlowSummary is the total row of fund flow bill
+type FundFlowSummary struct {
+	TotalCount  int
+	IncomeCount int
+	Income      Fen
+	ExpendCount int
+	Expend      Fen
+}
+
+// fundFlowColumns map the column names in header of fund flow bill to the setter of FundFlowRecord
+var fundFlowColumns = map[string]func(*FundFlowRecord, string) error{
+	"记账时间": func(r *FundFlowRecord, v string) (err error) {
+		r.BookTime, err = time.ParseInLocation(BillTimeLayout, v, ChinaTimeZone)
+		return err
+	},
+	"微信支付业务单号":  func(r *FundFlowRecord, v string) error { r.BizOrderNo = v; return nil },
+	"资金流水单号":    func(r *FundFlowRecord, v string) error { r.FlowNo = v; return nil },
+	"业务名称":      func(r *FundFlowRecord, v string) error { r.BizName = v; return nil },
+	"业务类型":      func(r *FundFlowRecord, v string) error { r.BizType = v; return nil },
+	"收支类型":      func(r *FundFlowRecord, v string) error { r.InOut = v; return nil },
+	"收支金额（元）":   func(r *FundFlowRecord, v string) (err error) { r.Amount, err = ParseYuan(v); return err },
+	"账户结余（元）":   func(r *FundFlowRecord, v string) (err error) { r.Balance, err = ParseYuan(v); return err },
+	"资金变更提交申请人": func(r *FundFlowRecord, v string) error { r.Applicant = v; return nil },
+	"备注":        func(r *FundFlowRecord, v string) error { r.Remark = v; return nil },
+	"业务凭证号":     func(r *FundFlowRecord, v string) error { r.VoucherNo = v; return nil },
+}
+
+// fundFlowSummaryColumns map the column names in summary header to the setter of FundFlowSummary
+var fundFlowSummaryColumns = map[string]func(*FundFlowSummary, string) error{
+	"资金流水总笔数": func(s *FundFlowSummary, v string) (err error) { s.TotalCount, err = strconv.Atoi(v); return err },
+	"收入笔数":    func(s *FundFlowSummary, v string) (err error) { s.IncomeCount, err = strconv.Atoi(v); return err },
+	"收入金额":    func(s *FundFlowSummary, v string) (err error) { s.Income, err = ParseYuan(v); return err },
+	"支出笔数":    func(s *FundFlowSummary, v string) (err error) { s.ExpendCount, err = strconv.Atoi(v); return err },
+	"支出金额":    func(s *FundFlowSummary, v string) (err error) { s.Expend, err = ParseYuan(v); return err },
+}
+
+// DownloadFundFlow download the fund flow bill of date(yyyyMMdd) and account type, and parse it.
+// The api requires HMAC-SHA256 sign and merchant certificate regardless of WxConfig.SignType.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=9_18&index=7
+func (this *AppTrans) DownloadFundFlow(date string, accountType FundAccountType) ([]FundFlowRecord, *FundFlowSummary, error) {
+	param := this.newParam()
+	param["bill_date"] = date
+	param["account_type"] = string(accountType)
+	param["tar_type"] = "GZIP"
+
+	data, _, err := this.callText(context.Background(), Endpoints[PathDownloadFundFlow], param)
+	if err != nil {
+		return nil, nil, err
+	}
+	if data, err = gunzipIfNeeded(data); err != nil {
+		return nil, nil, err
+	}
+	if data, err = decodeBillText(data, this.charsetReader()); err != nil {
+		return nil, nil, err
+	}
+
+	return ParseFundFlow(ParseBillTable(data))
+}
+
+// ParseFundFlow convert the split fund flow bill into typed records and summary, BillTable.Err is returned if set
+func ParseFundFlow(table *BillTable) ([]FundFlowRecord, *FundFlowSummary, error) {
+	if table.Err != nil {
+		return nil, nil, table.Err
+	}
+
+	var records []FundFlowRecord
+	for i, fields := range table.Rows {
+		if len(fields) != len(table.Header) {
+			return nil, nil, fmt.Errorf("fund flow row %d: %d fields, the header has %d", i+1, len(fields), len(table.Header))
+		}
+
+		record := FundFlowRecord{}
+		for j, name := range table.Header {
+			if set, ok := fundFlowColumns[name]; ok {
+				if err := set(&record, fields[j]); err != nil {
+					return nil, nil, fmt.Errorf("fund flow row %d column %s: %v", i+1, name, err)
+				}
+			}
+		}
+		records = append(records, record)
+	}
+
+	summary := &FundFlowSummary{}
+	if len(table.Summary) != len(table.SummaryHeader) {
+		return nil, nil, fmt.Errorf("fund flow summary has %d fields, the header has %d", len(table.Summary), len(table.SummaryHeader))
+	}
+	for i, name := range table.SummaryHeader {
+		if set, ok := fundFlowSummaryColumns[name]; ok {
+			if err := set(summary, table.Summary[i]); err != nil {
+				return nil, nil, fmt.Errorf("fund flow summary column %s: %v", name, err)
+			}
+		}
+	}
+
+	return records, summary, nil
+}
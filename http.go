@@ -2,22 +2,41 @@ package wxpay
 
 import (
 	"bytes"
+	"context"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 )
 
 // AppTrans is abstact of Transaction handler. With AppTrans, we can get prepay id
 type AppTrans struct {
 	Config *WxConfig
+
+	certMu   sync.Mutex
+	certHttp *http.Client
+
+	keyMu sync.Mutex
+	key   []byte
+
+	sandboxMu  sync.Mutex
+	sandboxKey string
+
+	bankKeyMu sync.Mutex
+	bankKey   *rsa.PublicKey
+
+	refundLocks keyedMutex
 }
 
 // Initialized the AppTrans with specific config
 func NewAppTrans(cfg *WxConfig) (*AppTrans, error) {
 	if cfg.AppId == "" ||
 		cfg.MchId == "" ||
-		cfg.AppKey == "" ||
+		(cfg.AppKey == "" && cfg.Secrets == nil) ||
 		cfg.NotifyUrl == "" ||
 		cfg.QueryOrderUrl == "" ||
 		cfg.PlaceOrderUrl == "" ||
@@ -25,6 +44,10 @@ func NewAppTrans(cfg *WxConfig) (*AppTrans, error) {
 		return &AppTrans{Config: cfg}, errors.New("config field canot empty string")
 	}
 
+	if !cfg.TradeType.Valid() {
+		return &AppTrans{Config: cfg}, fmt.Errorf("unknown trade type:%s", cfg.TradeType)
+	}
+
 	return &AppTrans{Config: cfg}, nil
 }
 
@@ -33,78 +56,211 @@ func NewAppTrans(cfg *WxConfig) (*AppTrans, error) {
 // If fail, error is not nil, check error for more information
 func (this *AppTrans) Submit(params map[string]string) (*PlaceOrderResult, error) {
 
-	odrInXml := this.signedOrderRequestXmlString(params)
-	resp, err := doHttpPost(this.Config.PlaceOrderUrl, []byte(odrInXml))
-	if err != nil {
+	order := this.newOrderRequest(params)
+	if err := validateOrderParams(order); err != nil {
+		return nil, err
+	}
+	if err := this.checkOpenId(order); err != nil {
 		return nil, err
 	}
 
-	placeOrderResult, err := ParsePlaceOrderResult(resp)
+	odrInXml, err := this.signedOrderRequestXmlString(order)
+	if err != nil {
+		return nil, err
+	}
+	resp, envelope, err := this.post(context.Background(), this.httpClient(), this.Config.PlaceOrderUrl, []byte(odrInXml))
 	if err != nil {
 		return nil, err
 	}
 
+	placeOrderResult := PlaceOrderResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &placeOrderResult); err != nil {
+		return nil, err
+	}
+
 	if placeOrderResult.ReturnCode != "SUCCESS" {
-		return nil, fmt.Errorf("return code:%s, return desc:%s", placeOrderResult.ReturnCode, placeOrderResult.ReturnMsg)
+		return nil, &ReturnError{ReturnCode: placeOrderResult.ReturnCode, ReturnMsg: placeOrderResult.ReturnMsg, Envelope: envelope}
 	}
 
 	if placeOrderResult.ResultCode != "SUCCESS" {
-		return nil, fmt.Errorf("resutl code:%s, result desc:%s", placeOrderResult.ErrCode, placeOrderResult.ErrCodeDesc)
+		return nil, &ResultError{ErrCode: placeOrderResult.ErrCode, ErrCodeDesc: placeOrderResult.ErrCodeDesc, Envelope: envelope}
 	}
 
 	//Verify the sign of response
-	resultInMap := placeOrderResult.ToMap()
-	wantSign := Sign(resultInMap, this.Config.AppKey)
-	gotSign := resultInMap["sign"]
-	if wantSign != gotSign {
-		return nil, fmt.Errorf("sign not match, want:%s, got:%s", wantSign, gotSign)
+	if err := this.verifyResponseSignWithType(PathUnifiedOrder, placeOrderResult.ToMap(), this.paramSignType(order)); err != nil {
+		return nil, err
+	}
+
+	if err := this.Config.TradeType.checkPlaceOrderResult(&placeOrderResult); err != nil {
+		return nil, err
 	}
 	return &placeOrderResult, nil
 }
 
-func (this *AppTrans) newQueryXml(transId string) string {
-	param := make(map[string]string)
+func (this *AppTrans) newQueryXml(param map[string]string) (string, error) {
 	param["appid"] = this.Config.AppId
 	param["mch_id"] = this.Config.MchId
-	param["transaction_id"] = transId
 	param["nonce_str"] = NewNonceString()
-
-	sign := Sign(param, this.Config.AppKey)
+	sign, err := this.sign(param)
+	if err != nil {
+		return "", err
+	}
 	param["sign"] = sign
 
-	return ToXmlString(param)
+	return ToXmlString(param), nil
 }
 
 // Query the order from weixin pay server by transaction id of weixin pay
 func (this *AppTrans) Query(transId string) (QueryOrderResult, error) {
+	return this.queryOrder(context.Background(), map[string]string{"transaction_id": transId})
+}
+
+// QueryByOutTradeNo query the order by out_trade_no of merchant, which is known before the payment completes
+func (this *AppTrans) QueryByOutTradeNo(outTradeNo string) (QueryOrderResult, error) {
+	return this.queryOrder(context.Background(), map[string]string{"out_trade_no": outTradeNo})
+}
+
+// queryOrder query the order by transaction_id or out_trade_no in param.
+// Response with return_code other than SUCCESS is not signed by weixin pay, it is returned as is
+// without sign verification, callers check ReturnCode and ResultCode of the result.
+func (this *AppTrans) queryOrder(ctx context.Context, param map[string]string) (QueryOrderResult, error) {
 	queryOrderResult := QueryOrderResult{}
 
-	queryXml := this.newQueryXml(transId)
-	// fmt.Println(queryXml)
-	resp, err := doHttpPost(this.Config.QueryOrderUrl, []byte(queryXml))
+	queryXml, err := this.newQueryXml(param)
 	if err != nil {
-		return queryOrderResult, nil
+		return queryOrderResult, err
 	}
-
-	queryOrderResult, err = ParseQueryOrderResult(resp)
+	resp, envelope, err := this.post(ctx, this.httpClient(), this.Config.QueryOrderUrl, []byte(queryXml))
 	if err != nil {
 		return queryOrderResult, err
 	}
+	queryOrderResult.Envelope = envelope
+
+	if err := this.unmarshalXml(resp, &queryOrderResult); err != nil {
+		return queryOrderResult, err
+	}
+	if queryOrderResult.ReturnCode != "SUCCESS" {
+		return queryOrderResult, nil
+	}
 
 	//verity sign of response
-	resultInMap := queryOrderResult.ToMap()
-	wantSign := Sign(resultInMap, this.Config.AppKey)
-	gotSign := resultInMap["sign"]
-	if wantSign != gotSign {
-		return queryOrderResult, fmt.Errorf("sign not match, want:%s, got:%s", wantSign, gotSign)
+	if err := this.verifyResponseSign(PathOrderQuery, queryOrderResult.ToMap()); err != nil {
+		return queryOrderResult, err
 	}
 
 	return queryOrderResult, nil
 }
 
+// verifyResponseSign verify the sign of response in form of map.
+// In strict mode(default), response without sign or with empty sign is rejected,
+// set WxConfig.DisableStrictSign to let them pass. Source is the api path reported to WxConfig.OnSignFailure.
+func (this *AppTrans) verifyResponseSign(source string, resp map[string]string) error {
+	return this.verifyResponseSignWithType(source, resp, this.Config.SignType)
+}
+
+// verifyResponseSignWithType is like verifyResponseSign but use the specific sign type.
+// Only responses with return_code SUCCESS are signed, others are rejected without reporting to
+// WxConfig.OnSignFailure, callers check return_code before.
+func (this *AppTrans) verifyResponseSignWithType(source string, resp map[string]string, signType string) error {
+	if resp["return_code"] != "SUCCESS" {
+		return &ReturnError{ReturnCode: resp["return_code"], ReturnMsg: resp["return_msg"]}
+	}
+
+	gotSign := resp["sign"]
+	if gotSign == "" {
+		if this.Config.DisableStrictSign {
+			return nil
+		}
+		return this.reportSignFailure(source, resp, errors.New("sign is missing in response"))
+	}
+
+	key, err := this.appKey()
+	if err != nil {
+		return err
+	}
+	if !VerifySignWithType(resp, key, signType) {
+		return this.reportSignFailure(source, resp, this.signError(resp, key, signType, true))
+	}
+	return nil
+}
+
+// verifyNotifySign verify the sign of message sent by weixin pay with its sign_type, unlike responses
+// messages without sign are always rejected
+func (this *AppTrans) verifyNotifySign(source string, notify map[string]string) error {
+	key, err := this.appKey()
+	if err != nil {
+		return err
+	}
+	signType := this.paramSignType(notify)
+	if !VerifySignWithType(notify, key, signType) {
+		return this.reportSignFailure(source, notify, this.signError(notify, key, signType, false))
+	}
+	return nil
+}
+
+// checkNotifyMerchant reject message sent by weixin pay for other merchant, by default appid and
+// mch_id must match the config, WxConfig.AcceptNotifyMerchant replace the check if it is set
+func (this *AppTrans) checkNotifyMerchant(notify map[string]string) error {
+	appId, mchId := notify["appid"], notify["mch_id"]
+	if this.Config.AcceptNotifyMerchant != nil {
+		if !this.Config.AcceptNotifyMerchant(appId, mchId) {
+			return fmt.Errorf("merchant not accepted, appid:%s, mch_id:%s", appId, mchId)
+		}
+		return nil
+	}
+
+	if appId != this.Config.AppId || mchId != this.Config.MchId {
+		return fmt.Errorf("merchant not match, want:%s/%s, got:%s/%s", this.Config.AppId, this.Config.MchId, appId, mchId)
+	}
+	return nil
+}
+
+// sign the request parameter with the sign type in config,
+// sign_type is added to the parameter if it is not MD5
+func (this *AppTrans) sign(param map[string]string) (string, error) {
+	return this.signWithType(param, this.paramSignType(param))
+}
+
+// paramSignType return the sign_type in param if it is set by caller for the call,
+// or the one in config
+func (this *AppTrans) paramSignType(param map[string]string) string {
+	if signType := param["sign_type"]; signType != "" {
+		return signType
+	}
+	return this.Config.SignType
+}
+
+// signWithType is like sign but use the specific sign type
+func (this *AppTrans) signWithType(param map[string]string, signType string) (string, error) {
+	if signType != "" && signType != SignTypeMD5 && signType != SignTypeHmacSha256 {
+		return "", fmt.Errorf("unsupported sign type:%s", signType)
+	}
+
+	key, err := this.appKey()
+	if err != nil {
+		return "", err
+	}
+
+	if signType != "" && signType != SignTypeMD5 {
+		param["sign_type"] = signType
+	}
+
+	return SignWithType(param, key, signType), nil
+}
+
 // NewPaymentRequest build the payment request structure for app to start a payment.
 // Return stuct of PaymentRequest, please refer to http://pay.weixin.qq.com/wiki/doc/api/app.php?chapter=9_12&index=2
+// If the key cannot be fetched from WxConfig.Secrets, Sign is left empty.
+//
+// Deprecated: the app fails with an unclear error if Sign is empty, use NewAppPaymentRequest instead.
 func (this *AppTrans) NewPaymentRequest(prepayId string) PaymentRequest {
+	payRequest, _ := this.NewAppPaymentRequest(prepayId)
+	return payRequest
+}
+
+// NewAppPaymentRequest is like NewPaymentRequest but return the error if the key cannot be fetched,
+// Sign is empty in that case
+func (this *AppTrans) NewAppPaymentRequest(prepayId string) (PaymentRequest, error) {
 	noncestr := NewNonceString()
 	timestamp := NewTimestampString()
 
@@ -116,8 +272,6 @@ func (this *AppTrans) NewPaymentRequest(prepayId string) PaymentRequest {
 	param["noncestr"] = noncestr
 	param["timestamp"] = timestamp
 
-	sign := Sign(param, this.Config.AppKey)
-
 	payRequest := PaymentRequest{
 		AppId:     this.Config.AppId,
 		PartnerId: this.Config.MchId,
@@ -125,10 +279,86 @@ func (this *AppTrans) NewPaymentRequest(prepayId string) PaymentRequest {
 		Package:   "Sign=WXPay",
 		NonceStr:  noncestr,
 		Timestamp: timestamp,
-		Sign:      sign,
 	}
 
-	return payRequest
+	key, err := this.appKey()
+	if err != nil {
+		return payRequest, err
+	}
+	payRequest.Sign = Sign(param, key)
+
+	return payRequest, nil
+}
+
+// NewJsApiPaymentRequest build the parameter of WeixinJSBridge getBrandWCPayRequest for JSAPI payment.
+// Sign type follows WxConfig.SignType and defaults to MD5.
+func (this *AppTrans) NewJsApiPaymentRequest(prepayId string) (JsApiPaymentRequest, error) {
+	return this.newJsApiPaymentRequest(this.Config.AppId, prepayId)
+}
+
+// NewPartnerJsApiPaymentRequest is like NewJsApiPaymentRequest for order placed by service provider.
+// If the order is placed with sub_appid and sub_openid, the payment is invoked in page of sub_appid and
+// subAppId should be the sub_appid, otherwise leave it empty to use AppId of config.
+// It is still signed with the key of service provider.
+func (this *AppTrans) NewPartnerJsApiPaymentRequest(subAppId, prepayId string) (JsApiPaymentRequest, error) {
+	appId := subAppId
+	if appId == "" {
+		appId = this.Config.AppId
+	}
+	return this.newJsApiPaymentRequest(appId, prepayId)
+}
+
+// newJsApiPaymentRequest build and sign the JSAPI payment parameter for the appid paying in
+func (this *AppTrans) newJsApiPaymentRequest(appId, prepayId string) (JsApiPaymentRequest, error) {
+	signType := this.Config.SignType
+	if signType == "" {
+		signType = SignTypeMD5
+	}
+
+	payRequest := JsApiPaymentRequest{
+		AppId:     appId,
+		Timestamp: NewTimestampString(),
+		NonceStr:  NewNonceString(),
+		Package:   "prepay_id=" + prepayId,
+		SignType:  signType,
+	}
+
+	param := make(map[string]string)
+	param["appId"] = payRequest.AppId
+	param["timeStamp"] = payRequest.Timestamp
+	param["nonceStr"] = payRequest.NonceStr
+	param["package"] = payRequest.Package
+	param["signType"] = payRequest.SignType
+	key, err := this.appKey()
+	if err != nil {
+		return payRequest, err
+	}
+	payRequest.PaySign = SignWithType(param, key, signType)
+
+	return payRequest, nil
+}
+
+// NewMiniProgramPaymentRequest build the parameter of wx.requestPayment for mini program payment,
+// AppId of config must be the appid of mini program. It is signed the same way as JSAPI.
+func (this *AppTrans) NewMiniProgramPaymentRequest(prepayId string) (MiniProgramPaymentRequest, error) {
+	return this.NewPartnerMiniProgramPaymentRequest("", prepayId)
+}
+
+// NewPartnerMiniProgramPaymentRequest is like NewMiniProgramPaymentRequest for order placed by
+// service provider with sub_appid of the mini program, see NewPartnerJsApiPaymentRequest
+func (this *AppTrans) NewPartnerMiniProgramPaymentRequest(subAppId, prepayId string) (MiniProgramPaymentRequest, error) {
+	jsApiRequest, err := this.NewPartnerJsApiPaymentRequest(subAppId, prepayId)
+	if err != nil {
+		return MiniProgramPaymentRequest{}, err
+	}
+
+	return MiniProgramPaymentRequest{
+		Timestamp: jsApiRequest.Timestamp,
+		NonceStr:  jsApiRequest.NonceStr,
+		Package:   jsApiRequest.Package,
+		SignType:  jsApiRequest.SignType,
+		PaySign:   jsApiRequest.PaySign,
+	}, nil
 }
 
 func (this *AppTrans) newOrderRequest(params map[string]string) map[string]string {
@@ -141,7 +371,7 @@ func (this *AppTrans) newOrderRequest(params map[string]string) map[string]strin
 	newParams["nonce_str"] = NewNonceString()
 	newParams["notify_url"] = this.Config.NotifyUrl
 	newParams["device_info"] = "WEB"
-	newParams["trade_type"] = this.Config.TradeType
+	newParams["trade_type"] = string(this.Config.TradeType)
 
 	//test data
 	//param["appid"] = "wxd930ea5d5a258f4f"
@@ -153,34 +383,270 @@ func (this *AppTrans) newOrderRequest(params map[string]string) map[string]strin
 	return newParams
 }
 
-func (this *AppTrans) signedOrderRequestXmlString(params map[string]string) string {
-	order := this.newOrderRequest(params)
-	sign := Sign(order, this.Config.AppKey)
-
+func (this *AppTrans) signedOrderRequestXmlString(order map[string]string) (string, error) {
+	sign, err := this.sign(order)
+	if err != nil {
+		return "", err
+	}
 	order["sign"] = sign
 
-	return ToXmlString(order)
+	return ToXmlString(order), nil
+}
+
+// newParam return the parameter with appid and mch_id of config
+func (this *AppTrans) newParam() map[string]string {
+	param := make(map[string]string)
+	param["appid"] = this.Config.AppId
+	param["mch_id"] = this.Config.MchId
+	return param
+}
+
+// Call fill nonce_str, sign the params and post them to the endpoint, the response is returned in map
+// after return_code and sign are checked. It can be used for endpoints without a typed method,
+// appid and mch_id(or their equivalents of the endpoint) must be in params.
+func (this *AppTrans) Call(ctx context.Context, ep Endpoint, params map[string]string) (map[string]string, error) {
+	param := make(map[string]string)
+	for k, v := range params {
+		param[k] = v
+	}
+
+	_, resp, _, err := this.call(ctx, ep, param)
+	return resp, err
+}
+
+// call fill nonce_str, sign the param and post it to the endpoint.
+// The response is returned both in raw and in map form with its envelope, after return_code and sign are checked.
+// Money-moving calls are recorded in WxConfig.Journal if it is set.
+func (this *AppTrans) call(ctx context.Context, ep Endpoint, param map[string]string) ([]byte, map[string]string, *Envelope, error) {
+	entry, err := this.journalBegin(ep, param)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	resp, respInMap, envelope, err := this.doCall(ctx, ep, param)
+	this.journalEnd(entry, respInMap, envelope, err)
+	return resp, respInMap, envelope, err
+}
+
+// doCall is call without journal
+func (this *AppTrans) doCall(ctx context.Context, ep Endpoint, param map[string]string) ([]byte, map[string]string, *Envelope, error) {
+	resp, envelope, err := this.send(ctx, ep, param)
+	if err != nil {
+		return nil, nil, envelope, err
+	}
+
+	respInMap, err := this.parseXmlMap(resp)
+	if err != nil {
+		return nil, nil, envelope, err
+	}
+
+	if respInMap["return_code"] != "SUCCESS" {
+		return nil, nil, envelope, &ReturnError{ReturnCode: respInMap["return_code"], ReturnMsg: respInMap["return_msg"], Envelope: envelope}
+	}
+
+	if !ep.Unsigned {
+		if err := this.verifyResponseSignWithType(ep.Path, respInMap, this.endpointSignType(ep, param)); err != nil {
+			return nil, nil, envelope, err
+		}
+	}
+
+	return resp, respInMap, envelope, nil
+}
+
+// callText is like call but for apis responding plain text on success, e.g. bill download.
+// An xml response is an error reported by weixin pay.
+func (this *AppTrans) callText(ctx context.Context, ep Endpoint, param map[string]string) ([]byte, *Envelope, error) {
+	resp, envelope, err := this.send(ctx, ep, param)
+	if err != nil {
+		return nil, envelope, err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(resp), []byte("<xml>")) {
+		return resp, envelope, nil
+	}
+
+	respInMap, err := this.parseXmlMap(resp)
+	if err != nil {
+		return nil, envelope, err
+	}
+	if isNoBillExist(respInMap) {
+		return nil, envelope, ErrNoBillExist
+	}
+	if respInMap["return_code"] != "SUCCESS" {
+		return nil, envelope, &ReturnError{ReturnCode: respInMap["return_code"], ReturnMsg: respInMap["return_msg"], Envelope: envelope}
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, envelope, err
+	}
+	return nil, envelope, fmt.Errorf("unexpected xml response: %s", resp)
+}
+
+// send fill nonce_str, sign the param and post it to the endpoint, the raw response is returned with its envelope
+func (this *AppTrans) send(ctx context.Context, ep Endpoint, param map[string]string) ([]byte, *Envelope, error) {
+	if param["nonce_str"] == "" {
+		param["nonce_str"] = NewNonceString()
+	}
+	sign, err := this.signWithType(param, this.endpointSignType(ep, param))
+	if err != nil {
+		return nil, nil, err
+	}
+	param["sign"] = sign
+
+	client := this.httpClient()
+	if ep.Cert {
+		if client, err = this.certClient(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return this.post(ctx, client, this.endpointUrl(ep), []byte(ToXmlString(param)))
+}
+
+// endpointSignType return the sign type required by endpoint, or the one of param
+func (this *AppTrans) endpointSignType(ep Endpoint, param map[string]string) string {
+	if ep.SignType != "" {
+		return ep.SignType
+	}
+	return this.paramSignType(param)
+}
+
+// ReturnError is returned when return_code of response is not SUCCESS, weixin pay rejected the request
+// definitely, e.g. for invalid parameters or sign, so it is not processed
+type ReturnError struct {
+	ReturnCode string
+	ReturnMsg  string
+	// Envelope is the transport level details of the response, nil if it is not known where the error is found
+	Envelope *Envelope
+}
+
+func (this *ReturnError) Error() string {
+	return fmt.Sprintf("return code:%s, return desc:%s", this.ReturnCode, this.ReturnMsg)
+}
+
+// ResultError is returned when result_code of response is not SUCCESS, check ErrCode for the reason
+type ResultError struct {
+	ErrCode     string
+	ErrCodeDesc string
+	// Envelope is the transport level details of the response, nil for notifications
+	Envelope *Envelope
+}
+
+func (this *ResultError) Error() string {
+	return fmt.Sprintf("result code:%s, result desc:%s", this.ErrCode, this.ErrCodeDesc)
+}
+
+// resultError return *ResultError with envelope of the response if result_code of the response is not SUCCESS
+func resultError(resp map[string]string, envelope *Envelope) error {
+	if resp["result_code"] != "SUCCESS" {
+		return &ResultError{ErrCode: resp["err_code"], ErrCodeDesc: resp["err_code_des"], Envelope: envelope}
+	}
+	return nil
+}
+
+// endpointUrl return the url of endpoint, on its own host if it has one
+func (this *AppTrans) endpointUrl(ep Endpoint) string {
+	if ep.Host != "" {
+		return ep.Host + ep.Path
+	}
+	return this.apiUrl(ep.Path)
+}
+
+// apiUrl return the url of api path on ApiHost
+func (this *AppTrans) apiUrl(path string) string {
+	host := this.Config.ApiHost
+	if host == "" {
+		host = DefaultApiHost
+	}
+	return host + path
+}
+
+// post send the body to targetUrl with client, and check the clock skew against the response.
+// The envelope is returned once the response is received, even with error of http status.
+// The request wait if the in-flight requests reach WxConfig.MaxInFlight.
+func (this *AppTrans) post(ctx context.Context, client *http.Client, targetUrl string, body []byte) ([]byte, *Envelope, error) {
+	release, err := this.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	requestUrl, err := this.sandboxUrl(targetUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	respData, envelope, err := doHttpPost(ctx, client, requestUrl, body)
+	if err != nil {
+		return respData, nil, err
+	}
+	envelope.Environment = this.Environment()
+	if this.Config.OnResponse != nil {
+		this.Config.OnResponse(targetUrl, envelope)
+	}
+	if envelope.StatusCode != http.StatusOK {
+		return respData, envelope, &HttpStatusError{Envelope: *envelope, Body: respData}
+	}
+
+	this.checkClockSkew(envelope.Header)
+	return respData, envelope, nil
 }
 
-// doRequest post the order in xml format with a sign
-func doHttpPost(targetUrl string, body []byte) ([]byte, error) {
-	req, err := http.NewRequest("POST", targetUrl, bytes.NewBuffer([]byte(body)))
+// checkClockSkew compare the Date header of response with local time,
+// WxConfig.OnClockSkew is called if the difference exceed WxConfig.ClockSkewThreshold
+func (this *AppTrans) checkClockSkew(header http.Header) {
+	if this.Config.OnClockSkew == nil {
+		return
+	}
+
+	serverTime, err := http.ParseTime(header.Get("Date"))
 	if err != nil {
-		return []byte(""), err
+		return
+	}
+
+	threshold := this.Config.ClockSkewThreshold
+	if threshold <= 0 {
+		threshold = DefaultClockSkewThreshold
 	}
 
-	client := &http.Client{}
+	skew := time.Since(serverTime)
+	if skew > threshold || skew < -threshold {
+		this.Config.OnClockSkew(skew)
+	}
+}
+
+// doHttpPost post the order in xml format with a sign, the request is canceled when ctx is done
+func doHttpPost(ctx context.Context, client *http.Client, targetUrl string, body []byte) ([]byte, *Envelope, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", targetUrl, bytes.NewBuffer([]byte(body)))
+	if err != nil {
+		return []byte(""), nil, err
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return []byte(""), err
+		return []byte(""), nil, err
 	}
 
 	defer resp.Body.Close()
 	respData, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return []byte(""), err
+		return []byte(""), nil, &url.Error{Op: "Post", URL: targetUrl, Err: err}
 	}
 
-	return respData, nil
+	return respData, &Envelope{StatusCode: resp.StatusCode, Header: resp.Header}, nil
+}
+
+// isTransportError tell whether err is failure of sending request or reading response, which can be
+// retried, rather than error of the response or made before sending. Errors caused by ctx are excluded.
+func isTransportError(ctx context.Context, err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && ctx.Err() == nil
+}
+
+// isOutcomeUnknown tell whether weixin pay may have processed the request though err is returned: it failed
+// in transport(including canceled by ctx after sending), or the response has http status other than 200 or
+// its sign does not match. Other errors are made before sending or a definite rejection like return_code FAIL.
+func isOutcomeUnknown(err error) bool {
+	var urlErr *url.Error
+	var statusErr *HttpStatusError
+	var signErr *SignError
+	return errors.As(err, &urlErr) || errors.As(err, &statusErr) || errors.As(err, &signErr)
 }
@@ -0,0 +1,224 @@
+package wxpay
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestGateway return AppTrans calling a fake weixin pay gateway, reply return the response body of
+// the request by api path and parameters. The config has a generated merchant certificate.
+func newTestGateway(t *testing.T, reply func(path string, param map[string]string) string) *AppTrans {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		param, _ := ParseXmlMap(body)
+		w.Header().Set("Request-ID", "req-1")
+		w.Write([]byte(reply(r.URL.Path, param)))
+	}))
+	t.Cleanup(server.Close)
+
+	certFile, keyFile := writeTestCert(t)
+	trans, err := NewAppTrans(&WxConfig{
+		AppId:         "wx1",
+		AppKey:        signExampleKey,
+		MchId:         "100",
+		NotifyUrl:     "https://example.com/notify",
+		PlaceOrderUrl: server.URL + PathUnifiedOrder,
+		QueryOrderUrl: server.URL + PathOrderQuery,
+		ApiHost:       server.URL,
+		CertFile:      certFile,
+		KeyFile:       keyFile,
+		TradeType:     TradeTypeApp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trans
+}
+
+// replyXml return the reply of newTestGateway answering every request with resp, signed if sign is set
+func replyXml(resp map[string]string, sign bool) func(string, map[string]string) string {
+	return func(string, map[string]string) string {
+		if sign {
+			return signedXml(resp)
+		}
+		return ToXmlString(resp)
+	}
+}
+
+// signedXml return resp in xml signed with signExampleKey
+func signedXml(resp map[string]string) string {
+	signed := make(map[string]string)
+	for k, v := range resp {
+		signed[k] = v
+	}
+	signed["sign"] = Sign(signed, signExampleKey)
+	return ToXmlString(signed)
+}
+
+// writeTestCert write a self-signed certificate and its key in PEM to temporary files
+func writeTestCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "apiclient_cert.pem"), filepath.Join(dir, "apiclient_key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestSubmit(t *testing.T) {
+	trans := newTestGateway(t, replyXml(map[string]string{
+		"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100",
+		"trade_type": "APP", "prepay_id": "wx201410272009395522657a690389285100",
+	}, true))
+
+	result, err := trans.Submit(map[string]string{"body": "test", "out_trade_no": "T1", "total_fee": "1", "spbill_create_ip": "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PrepayId != "wx201410272009395522657a690389285100" {
+		t.Errorf("got prepay id %s", result.PrepayId)
+	}
+	if result.Envelope == nil || result.Envelope.RequestId() != "req-1" || result.Envelope.Environment != EnvironmentProduction {
+		t.Errorf("unexpected envelope: %+v", result.Envelope)
+	}
+}
+
+func TestSubmitResultError(t *testing.T) {
+	trans := newTestGateway(t, replyXml(map[string]string{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "ORDERPAID"}, true))
+
+	_, err := trans.Submit(map[string]string{"body": "test", "out_trade_no": "T1", "total_fee": "1", "spbill_create_ip": "127.0.0.1"})
+	resultErr, ok := err.(*ResultError)
+	if !ok || resultErr.ErrCode != "ORDERPAID" || resultErr.Envelope == nil || resultErr.Envelope.StatusCode != http.StatusOK {
+		t.Errorf("unexpected error: %#v", err)
+	}
+}
+
+func TestQueryReturnFail(t *testing.T) {
+	trans := newTestGateway(t, replyXml(map[string]string{"return_code": "FAIL", "return_msg": "mch_id invalid"}, false))
+	failures := 0
+	trans.Config.OnSignFailure = func(SignFailure) { failures++ }
+
+	_, err := trans.ConfirmPayment(context.Background(), "T1", 1)
+	if err == nil || err.Error() != "return code:FAIL, return desc:mch_id invalid" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if failures != 0 {
+		t.Errorf("unsigned FAIL response is reported as %d sign failures", failures)
+	}
+}
+
+func TestQuerySignMismatch(t *testing.T) {
+	trans := newTestGateway(t, replyXml(map[string]string{"return_code": "SUCCESS", "result_code": "SUCCESS", "sign": "FORGED"}, false))
+	failures := 0
+	trans.Config.OnSignFailure = func(SignFailure) { failures++ }
+
+	if _, err := trans.QueryByOutTradeNo("T1"); err == nil {
+		t.Error("want error for forged sign")
+	}
+	if failures != 1 {
+		t.Errorf("got %d sign failures, want 1", failures)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	trans := newTestGateway(t, func(string, map[string]string) string {
+		time.Sleep(200 * time.Millisecond)
+		return ""
+	})
+	trans.Config.Timeout = 20 * time.Millisecond
+
+	start := time.Now()
+	_, err := trans.QueryByOutTradeNo("T1")
+	if !isTransportError(context.Background(), err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("request is not timed out, it takes %s", elapsed)
+	}
+}
+
+func TestCertClientRetryFailedLoad(t *testing.T) {
+	trans := newTestGateway(t, replyXml(map[string]string{"return_code": "SUCCESS"}, true))
+	keyFile := trans.Config.KeyFile
+	trans.Config.KeyFile = keyFile + ".missing"
+	if _, err := trans.certClient(); err == nil {
+		t.Fatal("want error for missing key file")
+	}
+
+	trans.Config.KeyFile = keyFile
+	client, err := trans.certClient()
+	if err != nil {
+		t.Fatalf("fixed certificate is not loaded: %v", err)
+	}
+	if client.Timeout != DefaultTimeout {
+		t.Errorf("got timeout %s", client.Timeout)
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	var skews []time.Duration
+	trans := &AppTrans{Config: &WxConfig{
+		ClockSkewThreshold: time.Minute,
+		OnClockSkew:        func(skew time.Duration) { skews = append(skews, skew) },
+	}}
+	for _, offset := range []time.Duration{0, 30 * time.Second, -30 * time.Second, 2 * time.Minute, -2 * time.Minute} {
+		header := http.Header{}
+		header.Set("Date", time.Now().Add(offset).UTC().Format(http.TimeFormat))
+		trans.checkClockSkew(header)
+	}
+	trans.checkClockSkew(http.Header{"Date": {"not a date"}})
+
+	if len(skews) != 2 {
+		t.Fatalf("OnClockSkew is called with %v", skews)
+	}
+	// local time minus server time, rounded to seconds by the Date header
+	if skews[0] > -time.Minute || skews[1] < time.Minute {
+		t.Errorf("unexpected skews %v", skews)
+	}
+}
+
+func TestCheckClockSkewOfResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-5*time.Minute).UTC().Format(http.TimeFormat))
+		w.Write([]byte(signedXml(map[string]string{"return_code": "SUCCESS"})))
+	}))
+	defer server.Close()
+
+	var skews []time.Duration
+	trans := &AppTrans{Config: &WxConfig{OnClockSkew: func(skew time.Duration) { skews = append(skews, skew) }}}
+	if _, _, err := trans.post(context.Background(), http.DefaultClient, server.URL, []byte("<xml></xml>")); err != nil {
+		t.Fatal(err)
+	}
+	if len(skews) != 1 || skews[0] < 4*time.Minute {
+		t.Errorf("skew of response exceed DefaultClockSkewThreshold is not reported: %v", skews)
+	}
+}
@@ -0,0 +1,48 @@
+package wxpay
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// MaxIpLength is the max length of spbill_create_ip accepted by weixin pay
+const MaxIpLength = 64
+
+// ClientIp extract the client ip from http request, the result can be used as spbill_create_ip.
+// If trustForwarded is true, the left-most address of X-Forwarded-For (or X-Real-IP) is used,
+// only enable it when the server is behind a trusted reverse proxy, otherwise the header can be forged.
+func ClientIp(req *http.Request, trustForwarded bool) (string, error) {
+	if trustForwarded {
+		if fwd := req.Header.Get("X-Forwarded-For"); fwd != "" {
+			ip := strings.TrimSpace(strings.Split(fwd, ",")[0])
+			return ip, ValidateIp(ip)
+		}
+		if ip := strings.TrimSpace(req.Header.Get("X-Real-IP")); ip != "" {
+			return ip, ValidateIp(ip)
+		}
+	}
+
+	ip := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	return ip, ValidateIp(ip)
+}
+
+// ValidateIp check the ip is a well-formed IPv4 or IPv6 address for spbill_create_ip
+func ValidateIp(ip string) error {
+	if ip == "" {
+		return fmt.Errorf("spbill_create_ip cannot be empty")
+	}
+	if len(ip) > MaxIpLength {
+		return fmt.Errorf("spbill_create_ip too long:%s", ip)
+	}
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid spbill_create_ip:%s", ip)
+	}
+
+	return nil
+}
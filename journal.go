@@ -0,0 +1,194 @@
+package wxpay
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalStatus is the state of a journaled call
+type JournalStatus string
+
+const (
+	// JournalPending is recorded before the request is sent
+	JournalPending JournalStatus = "PENDING"
+	// JournalSucceeded means result_code is SUCCESS
+	JournalSucceeded JournalStatus = "SUCCEEDED"
+	// JournalFailed means weixin pay rejected the request definitely
+	JournalFailed JournalStatus = "FAILED"
+	// JournalUnknown means the result is unknown(transport error, http status other than 200, sign mismatch,
+	// unreadable response or SYSTEMERROR), query it before retrying
+	JournalUnknown JournalStatus = "UNKNOWN"
+	// JournalNotSent means the request failed before it was sent, e.g. rejected by WxConfig.MaxInFlight or
+	// the certificate failed to load, so weixin pay never received it
+	JournalNotSent JournalStatus = "NOT_SENT"
+)
+
+// journalKeys map the money-moving apis to their idempotency key field
+var journalKeys = map[string]string{
+	PathReverse:          "out_trade_no",
+	PathRefund:           "out_refund_no",
+	PathTransfers:        "partner_trade_no",
+	PathPayBank:          "partner_trade_no",
+	PathSendRedPack:      "mch_billno",
+	PathSendGroupRedPack: "mch_billno",
+	PathWorkWxTransfer:   "partner_trade_no",
+	PathWorkWxRedPack:    "mch_billno",
+
+	PathProfitSharing:       "out_order_no",
+	PathMultiProfitSharing:  "out_order_no",
+	PathProfitSharingFinish: "out_order_no",
+}
+
+// JournalEntry is a record of an attempt of money-moving call, each attempt has a PENDING entry
+// appended before sending and an entry of the outcome after
+type JournalEntry struct {
+	Time time.Time
+	Path string
+	// Key is the idempotency key of the call, e.g. out_refund_no for refund
+	Key string
+	// RequestHash is the sha256 in hex of the request parameters without nonce_str, sign and the
+	// fields encrypted randomly(enc_ prefixed, e.g. enc_bank_no), retries of the same request have the same hash
+	RequestHash string
+	Status      JournalStatus
+	ErrCode     string
+	Err         string
+	// Environment is the environment the call is sent to, entries of sandbox are not real money
+	Environment Environment
+}
+
+// Journal store the entries of money-moving calls(refund, reverse, transfer, red packet and profit sharing),
+// implement it with a durable storage so what was sent can be proven after crash
+type Journal interface {
+	// Append record the entry, the call is not sent if appending PENDING entry fails
+	Append(entry JournalEntry) error
+	// Entries return the entries of key for the api path in appended order, keys of different apis
+	// are independent, e.g. out_trade_no of reverse and partner_trade_no of transfer
+	Entries(path, key string) ([]JournalEntry, error)
+	// Unresolved return the last entry of (path, key)s whose last status is PENDING or UNKNOWN
+	Unresolved() ([]JournalEntry, error)
+}
+
+// MemoryJournal is a Journal in process memory, for tests and single process tools
+type MemoryJournal struct {
+	mu      sync.Mutex
+	keys    []journalKey
+	entries map[journalKey][]JournalEntry
+}
+
+// journalKey identify the entries of a call, the key is scoped by api path
+type journalKey struct {
+	path string
+	key  string
+}
+
+// NewMemoryJournal return an empty MemoryJournal
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{entries: make(map[journalKey][]JournalEntry)}
+}
+
+func (this *MemoryJournal) Append(entry JournalEntry) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	key := journalKey{path: entry.Path, key: entry.Key}
+	if _, ok := this.entries[key]; !ok {
+		this.keys = append(this.keys, key)
+	}
+	this.entries[key] = append(this.entries[key], entry)
+	return nil
+}
+
+func (this *MemoryJournal) Entries(path, key string) ([]JournalEntry, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	return append([]JournalEntry(nil), this.entries[journalKey{path: path, key: key}]...), nil
+}
+
+func (this *MemoryJournal) Unresolved() ([]JournalEntry, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	var unresolved []JournalEntry
+	for _, key := range this.keys {
+		entries := this.entries[key]
+		last := entries[len(entries)-1]
+		if last.Status == JournalPending || last.Status == JournalUnknown {
+			unresolved = append(unresolved, last)
+		}
+	}
+	return unresolved, nil
+}
+
+// journalBegin append the PENDING entry if the call is journaled, nil is returned otherwise
+func (this *AppTrans) journalBegin(ep Endpoint, param map[string]string) (*JournalEntry, error) {
+	keyField, ok := journalKeys[ep.Path]
+	if this.Config.Journal == nil || !ok {
+		return nil, nil
+	}
+
+	entry := &JournalEntry{
+		Time:        time.Now(),
+		Path:        ep.Path,
+		Key:         param[keyField],
+		RequestHash: journalRequestHash(param),
+		Status:      JournalPending,
+		Environment: this.Environment(),
+	}
+	if err := this.Config.Journal.Append(*entry); err != nil {
+		return nil, fmt.Errorf("journal not available, %s is not sent: %v", ep.Path, err)
+	}
+	return entry, nil
+}
+
+// journalRequestHash return the RequestHash of the request parameters
+func journalRequestHash(param map[string]string) string {
+	hashed := make(map[string]string)
+	for k, v := range param {
+		// encryption with OAEP is randomized, the encrypted fields differ on each retry
+		if k != "nonce_str" && k != "sign" && !strings.HasPrefix(k, "enc_") {
+			hashed[k] = v
+		}
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(SortAndConcat(hashed))))
+}
+
+// journalEnd append the outcome of the journaled call, failure is only logged as the call is done.
+// The envelope is not nil once a response is received.
+func (this *AppTrans) journalEnd(entry *JournalEntry, respInMap map[string]string, envelope *Envelope, err error) {
+	if entry == nil {
+		return
+	}
+
+	outcome := *entry
+	outcome.Time = time.Now()
+	var returnErr *ReturnError
+	switch {
+	case err != nil && errors.As(err, &returnErr):
+		outcome.Status = JournalFailed
+		outcome.Err = err.Error()
+	case err != nil && (isOutcomeUnknown(err) || envelope != nil):
+		outcome.Status = JournalUnknown
+		outcome.Err = err.Error()
+	case err != nil:
+		outcome.Status = JournalNotSent
+		outcome.Err = err.Error()
+	case respInMap["result_code"] == "SUCCESS":
+		outcome.Status = JournalSucceeded
+	default:
+		outcome.Status = JournalFailed
+		if respInMap["err_code"] == "SYSTEMERROR" {
+			outcome.Status = JournalUnknown
+		}
+		outcome.ErrCode = respInMap["err_code"]
+		outcome.Err = respInMap["err_code_des"]
+	}
+
+	if err := this.Config.Journal.Append(outcome); err != nil {
+		this.logf("wxpay: failed to journal %s of %s %s: %v", outcome.Status, outcome.Path, outcome.Key, err)
+	}
+}
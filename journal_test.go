@@ -0,0 +1,135 @@
+package wxpay
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryJournalKeyedByPath(t *testing.T) {
+	journal := NewMemoryJournal()
+	journal.Append(JournalEntry{Path: PathReverse, Key: "1001", Status: JournalPending})
+	journal.Append(JournalEntry{Path: PathTransfers, Key: "1001", Status: JournalPending})
+	journal.Append(JournalEntry{Path: PathTransfers, Key: "1001", Status: JournalSucceeded})
+
+	reverses, _ := journal.Entries(PathReverse, "1001")
+	transfers, _ := journal.Entries(PathTransfers, "1001")
+	if len(reverses) != 1 || len(transfers) != 2 {
+		t.Errorf("got %d reverse and %d transfer entries", len(reverses), len(transfers))
+	}
+
+	unresolved, _ := journal.Unresolved()
+	if len(unresolved) != 1 || unresolved[0].Path != PathReverse {
+		t.Errorf("unexpected unresolved: %v", unresolved)
+	}
+}
+
+func TestJournalRequestHash(t *testing.T) {
+	trans := &AppTrans{Config: &WxConfig{Journal: NewMemoryJournal()}}
+	param := map[string]string{"partner_trade_no": "P1", "amount": "100", "enc_bank_no": "a", "nonce_str": "n1"}
+	first, err := trans.journalBegin(Endpoints[PathPayBank], param)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	param["enc_bank_no"], param["nonce_str"] = "b", "n2"
+	retry, _ := trans.journalBegin(Endpoints[PathPayBank], param)
+	if first.RequestHash != retry.RequestHash {
+		t.Error("retry of the same request has different hash")
+	}
+
+	param["amount"] = "200"
+	changed, _ := trans.journalBegin(Endpoints[PathPayBank], param)
+	if changed.RequestHash == first.RequestHash {
+		t.Error("changed request has the same hash")
+	}
+}
+
+func TestDeriveOutRefundNo(t *testing.T) {
+	if no := DeriveOutRefundNo("T1", 2); no != "T1-R2" {
+		t.Errorf("got %s", no)
+	}
+	long := DeriveOutRefundNo(strings.Repeat("1", 64), 1)
+	if len(long) > maxOutRefundNoLength || long != DeriveOutRefundNo(strings.Repeat("1", 64), 1) {
+		t.Errorf("got %s", long)
+	}
+}
+
+func TestRefundOnceRejectChangedRequest(t *testing.T) {
+	journal := NewMemoryJournal()
+	trans := &AppTrans{Config: &WxConfig{AppId: "wx1", MchId: "100", AppKey: signExampleKey, Journal: journal}}
+	journal.Append(JournalEntry{Path: PathRefund, Key: "T1-R1", RequestHash: "other", Status: JournalUnknown})
+
+	_, err := trans.RefundOnce(&RefundRequest{OutTradeNo: "T1", TotalFee: 100, RefundFee: 50}, 1)
+	if err == nil || !strings.Contains(err.Error(), "different refund request") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestJournalOutcome(t *testing.T) {
+	cases := []struct {
+		name  string
+		reply map[string]string
+		cert  bool
+		want  JournalStatus
+	}{
+		{"succeeded", map[string]string{"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100"}, true, JournalSucceeded},
+		{"return fail", map[string]string{"return_code": "FAIL", "return_msg": "invalid refund_fee"}, true, JournalFailed},
+		{"forged sign", map[string]string{"return_code": "SUCCESS", "result_code": "SUCCESS", "sign": "FORGED"}, true, JournalUnknown},
+		{"no cert", nil, false, JournalNotSent},
+	}
+	for _, c := range cases {
+		journal := NewMemoryJournal()
+		trans := newTestGateway(t, func(path string, param map[string]string) string {
+			if c.reply["sign"] != "" {
+				return ToXmlString(c.reply)
+			}
+			return signedXml(c.reply)
+		})
+		trans.Config.Journal = journal
+		if !c.cert {
+			trans.Config.CertFile = ""
+		}
+
+		trans.RefundOrder(&RefundRequest{OutTradeNo: "T1", OutRefundNo: "R1", TotalFee: 100, RefundFee: 50})
+		entries, _ := journal.Entries(PathRefund, "R1")
+		if len(entries) != 2 || entries[1].Status != c.want {
+			t.Errorf("%s: unexpected entries %+v", c.name, entries)
+		}
+		unresolved, _ := journal.Unresolved()
+		if resolved := c.want != JournalUnknown; resolved != (len(unresolved) == 0) {
+			t.Errorf("%s: unexpected unresolved %+v", c.name, unresolved)
+		}
+	}
+}
+
+func TestRefundOnceConcurrentChangedRequest(t *testing.T) {
+	calls := &testCalls{}
+	trans := newTestGateway(t, func(path string, param map[string]string) string {
+		calls.add(path)
+		time.Sleep(20 * time.Millisecond)
+		return signedXml(map[string]string{"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100"})
+	})
+	trans.Config.Journal = NewMemoryJournal()
+
+	errs := make(chan error, 2)
+	for _, fee := range []int64{30, 50} {
+		go func(fee int64) {
+			_, err := trans.RefundOnce(&RefundRequest{OutTradeNo: "T1", TotalFee: 100, RefundFee: fee}, 1)
+			errs <- err
+		}(fee)
+	}
+
+	failed := 0
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			if !strings.Contains(err.Error(), "different refund request") {
+				t.Errorf("unexpected error: %v", err)
+			}
+			failed++
+		}
+	}
+	if failed != 1 || calls.get(PathRefund) != 1 {
+		t.Errorf("%d refunds refused, %d sent", failed, calls.get(PathRefund))
+	}
+}
@@ -0,0 +1,73 @@
+package wxpay
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// OverloadedError is returned when the in-flight requests of the merchant reach WxConfig.MaxInFlight
+// and WxConfig.MaxQueued requests are already waiting
+type OverloadedError struct {
+	MchId string
+}
+
+func (this *OverloadedError) Error() string {
+	return "too many in-flight requests for merchant " + this.MchId
+}
+
+// merchantLimit is the in-flight slots of one merchant
+type merchantLimit struct {
+	slots  chan struct{}
+	queued atomic.Int64
+}
+
+// merchantLimits is shared by all AppTrans of the process, so AppTrans of the same MchId share the slots
+var merchantLimits = struct {
+	sync.Mutex
+	limits map[string]*merchantLimit
+}{limits: make(map[string]*merchantLimit)}
+
+// limitOf return the slots of the merchant, they are created with size on the first call of the merchant
+func limitOf(mchId string, size int) *merchantLimit {
+	merchantLimits.Lock()
+	defer merchantLimits.Unlock()
+
+	limit, ok := merchantLimits.limits[mchId]
+	if !ok {
+		limit = &merchantLimit{slots: make(chan struct{}, size)}
+		merchantLimits.limits[mchId] = limit
+	}
+	return limit
+}
+
+// acquire wait for a slot of in-flight requests of the merchant, it return a function to release the slot.
+// The slots are shared by every AppTrans with the same MchId, the MaxInFlight of the first one to send
+// a request decides the number of the slots.
+func (this *AppTrans) acquire(ctx context.Context) (func(), error) {
+	if this.Config.MaxInFlight <= 0 {
+		return func() {}, nil
+	}
+
+	limit := limitOf(this.Config.MchId, this.Config.MaxInFlight)
+	release := func() { <-limit.slots }
+
+	select {
+	case limit.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if limit.queued.Add(1) > int64(this.Config.MaxQueued) {
+		limit.queued.Add(-1)
+		return nil, &OverloadedError{MchId: this.Config.MchId}
+	}
+	defer limit.queued.Add(-1)
+
+	select {
+	case limit.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
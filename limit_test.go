@@ -0,0 +1,86 @@
+package wxpay
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquireSharedByMerchant(t *testing.T) {
+	cfg := WxConfig{MchId: "limit-shared", MaxInFlight: 1, MaxQueued: 1}
+	first := &AppTrans{Config: &cfg}
+	secondCfg := cfg
+	second := &AppTrans{Config: &secondCfg}
+
+	release, err := first.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		release, err := second.acquire(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- release
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("slot of the merchant is not shared")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if _, err := first.acquire(context.Background()); err == nil {
+		t.Fatal("queue of the merchant is not shared")
+	} else if overloaded, ok := err.(*OverloadedError); !ok || overloaded.MchId != "limit-shared" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	release()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("queued request is not given the released slot")
+	}
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	trans := &AppTrans{Config: &WxConfig{MchId: "limit-canceled", MaxInFlight: 1, MaxQueued: 1}}
+	release, err := trans.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := trans.acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the canceled request leave the queue
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := trans.acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAcquireOtherMerchant(t *testing.T) {
+	first := &AppTrans{Config: &WxConfig{MchId: "limit-a", MaxInFlight: 1}}
+	second := &AppTrans{Config: &WxConfig{MchId: "limit-b", MaxInFlight: 1}}
+	release, err := first.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	release, err = second.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("slot of other merchant is taken: %v", err)
+	}
+	release()
+}
@@ -0,0 +1,57 @@
+package wxpay
+
+import (
+	"sync"
+	"time"
+)
+
+// Locker grant the lock of a shared background task to one instance among replicas, e.g. so only one
+// OutboxDispatcher publish the events. Implement it with a shared storage:
+//   - redis: SET name owner NX PX ttl to acquire, and a script comparing the value with owner to extend
+//     (PEXPIRE) or release (DEL) it
+//   - etcd: a lease of ttl kept alive by the owner, with the key created in a transaction if absent
+type Locker interface {
+	// TryLock acquire the lock of name for owner until ttl passed, or extend it if owner already holds it.
+	// It return false without error if another owner holds the lock.
+	TryLock(name, owner string, ttl time.Duration) (bool, error)
+	// Unlock release the lock of name if owner holds it
+	Unlock(name, owner string) error
+}
+
+// MemoryLocker is a Locker in process memory, for tests and multiple workers in one process
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	owner  string
+	expire time.Time
+}
+
+// NewMemoryLocker return a MemoryLocker without locks held
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]memoryLock)}
+}
+
+func (this *MemoryLocker) TryLock(name, owner string, ttl time.Duration) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	now := time.Now()
+	if lock, ok := this.locks[name]; ok && lock.owner != owner && now.Before(lock.expire) {
+		return false, nil
+	}
+	this.locks[name] = memoryLock{owner: owner, expire: now.Add(ttl)}
+	return true, nil
+}
+
+func (this *MemoryLocker) Unlock(name, owner string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if lock, ok := this.locks[name]; ok && lock.owner == owner {
+		delete(this.locks, name)
+	}
+	return nil
+}
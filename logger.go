@@ -0,0 +1,38 @@
+package wxpay
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// Logger is used to report problems that cannot be returned to caller, e.g. panic in notification handler.
+// *log.Logger satisfy it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logf log with WxConfig.Logger, or the standard logger if not set
+func (this *AppTrans) logf(format string, v ...interface{}) {
+	if this.Config.Sandbox {
+		format = "[" + string(EnvironmentSandbox) + "] " + format
+	}
+	if this.Config.Logger != nil {
+		this.Config.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// safeCall call the business callback fn, panic in fn is recovered, logged and returned as error,
+// so a bad callback cannot take down the server or leave weixin pay without reply
+func (this *AppTrans) safeCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			this.logf("wxpay: panic in notification handler: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic in handler: %v", r)
+		}
+	}()
+
+	return fn()
+}
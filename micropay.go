@@ -0,0 +1,347 @@
+package wxpay
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// MicropayQueryInterval is the interval of querying the order while user is paying
+	MicropayQueryInterval = 5 * time.Second
+	// MicropayTimeout is how long to wait for user paying before the order is reversed
+	MicropayTimeout = 30 * time.Second
+
+	// ReverseMaxAttempts is the max times of calling reverse api when recall is required
+	ReverseMaxAttempts = 10
+	// ReverseRetryInterval is the interval between reverse attempts
+	ReverseRetryInterval = time.Second
+)
+
+// ReverseBackoff is the retry policy of Reverse, a constant ReverseRetryInterval for ReverseMaxAttempts
+var ReverseBackoff = Backoff{Initial: ReverseRetryInterval, MaxAttempts: ReverseMaxAttempts}
+
+// MicropayResult represent micropay(刷卡支付) response message from weixin pay.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/micropay.php?chapter=9_10&index=1
+type MicropayResult struct {
+	XMLName            xml.Name `xml:"xml"`
+	ReturnCode         string   `xml:"return_code"`
+	ReturnMsg          string   `xml:"return_msg"`
+	AppId              string   `xml:"appid"`
+	MchId              string   `xml:"mch_id"`
+	DeviceInfo         string   `xml:"device_info"`
+	NonceStr           string   `xml:"nonce_str"`
+	Sign               string   `xml:"sign"`
+	ResultCode         string   `xml:"result_code"`
+	ErrCode            string   `xml:"err_code"`
+	ErrCodeDesc        string   `xml:"err_code_des"`
+	OpenId             string   `xml:"openid"`
+	IsSubscribe        string   `xml:"is_subscribe"`
+	TradeType          string   `xml:"trade_type"`
+	BankType           string   `xml:"bank_type"`
+	FeeType            string   `xml:"fee_type"`
+	TotalFee           string   `xml:"total_fee"`
+	SettlementTotalFee string   `xml:"settlement_total_fee"`
+	CouponFee          string   `xml:"coupon_fee"`
+	CashFeeType        string   `xml:"cash_fee_type"`
+	CashFee            string   `xml:"cash_fee"`
+	TransactionId      string   `xml:"transaction_id"`
+	OutTradeNo         string   `xml:"out_trade_no"`
+	Attach             string   `xml:"attach"`
+	TimeEnd            string   `xml:"time_end"`
+	PromotionDetail    string   `xml:"promotion_detail"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// ReverseResult represent reverse(撤销订单) response message from weixin pay.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/micropay.php?chapter=9_11&index=3
+type ReverseResult struct {
+	XMLName     xml.Name `xml:"xml"`
+	ReturnCode  string   `xml:"return_code"`
+	ReturnMsg   string   `xml:"return_msg"`
+	AppId       string   `xml:"appid"`
+	MchId       string   `xml:"mch_id"`
+	NonceStr    string   `xml:"nonce_str"`
+	Sign        string   `xml:"sign"`
+	ResultCode  string   `xml:"result_code"`
+	ErrCode     string   `xml:"err_code"`
+	ErrCodeDesc string   `xml:"err_code_des"`
+	// Recall is Y if the reverse should be called again
+	Recall string `xml:"recall"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// Micropay charge the user by the auth_code scanned from user's barcode, params is like
+// OrderRequest.ToMap() plus auth_code. If user need to input password, *ResultError with
+// ErrCode USERPAYING is returned, use MicropayAndWait to handle it.
+func (this *AppTrans) Micropay(params map[string]string) (*MicropayResult, error) {
+	return this.micropay(context.Background(), params)
+}
+
+func (this *AppTrans) micropay(ctx context.Context, params map[string]string) (*MicropayResult, error) {
+	param := this.newParam()
+	for k, v := range params {
+		param[k] = v
+	}
+
+	resp, respInMap, envelope, err := this.call(ctx, Endpoints[PathMicropay], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	micropayResult := MicropayResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &micropayResult); err != nil {
+		return nil, err
+	}
+	return &micropayResult, nil
+}
+
+// Reverse cancel the micropay order by out_trade_no, see ReverseContext
+func (this *AppTrans) Reverse(outTradeNo string) (*ReverseResult, error) {
+	return this.ReverseContext(context.Background(), outTradeNo)
+}
+
+// ReverseContext cancel the micropay order by out_trade_no, the paid amount is refunded to user.
+// As documented, the reverse is called again while weixin pay respond recall=Y or the request
+// fail in transport, following ReverseBackoff. Other errors are returned at once, and an error is
+// returned if recall is still required after the attempts run out. Waiting between attempts stops
+// with the error of ctx once ctx is done. Merchant certificate is required.
+func (this *AppTrans) ReverseContext(ctx context.Context, outTradeNo string) (*ReverseResult, error) {
+	for attempt := 1; ; attempt++ {
+		reverseResult, err := this.reverse(ctx, outTradeNo)
+		recall := reverseResult != nil && reverseResult.Recall == "Y"
+		if !recall && (reverseResult != nil || !isTransportError(ctx, err)) {
+			return reverseResult, err
+		}
+
+		if !ReverseBackoff.Allow(attempt + 1) {
+			if err == nil {
+				err = errors.New("recall is still required")
+			}
+			return reverseResult, fmt.Errorf("order %s is not reversed after %d attempts: %v", outTradeNo, attempt, err)
+		}
+		if err := ReverseBackoff.Wait(ctx, attempt); err != nil {
+			return reverseResult, err
+		}
+	}
+}
+
+// reverse call the reverse api once, result is returned with *ResultError if result_code is not SUCCESS
+func (this *AppTrans) reverse(ctx context.Context, outTradeNo string) (*ReverseResult, error) {
+	param := this.newParam()
+	param["out_trade_no"] = outTradeNo
+
+	resp, respInMap, envelope, err := this.call(ctx, Endpoints[PathReverse], param)
+	if err != nil {
+		return nil, err
+	}
+
+	reverseResult := ReverseResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &reverseResult); err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return &reverseResult, err
+	}
+	return &reverseResult, nil
+}
+
+// MicropayPolicy is the strategy of waiting for micropay whose result is unknown(USERPAYING, or
+// system/bank error): the order is queried following Poll until it is paid or failed, and reversed
+// if it is still not paid after Timeout or Poll.MaxAttempts queries.
+type MicropayPolicy struct {
+	Poll    Backoff
+	Timeout time.Duration
+}
+
+// DefaultMicropayPolicy is the policy recommended by weixin pay, query every MicropayQueryInterval
+// and reverse after MicropayTimeout
+var DefaultMicropayPolicy = MicropayPolicy{Poll: Backoff{Initial: MicropayQueryInterval}, Timeout: MicropayTimeout}
+
+// MicropayError is returned when the micropay order is not paid in the end
+type MicropayError struct {
+	OutTradeNo string
+	// TradeState is the final state of order, empty if the order is reversed for timeout
+	TradeState     string
+	TradeStateDesc string
+	// Reversed tell the order is reversed, ReverseErr is the error of reverse if it failed
+	Reversed   bool
+	ReverseErr error
+}
+
+func (this *MicropayError) Error() string {
+	if this.ReverseErr != nil {
+		return fmt.Sprintf("micropay timeout and reverse failed: %v", this.ReverseErr)
+	}
+	if this.Reversed {
+		return fmt.Sprintf("micropay timeout, order %s is reversed", this.OutTradeNo)
+	}
+	return fmt.Sprintf("micropay failed, trade state:%s, desc:%s", this.TradeState, this.TradeStateDesc)
+}
+
+// MicropayAndWait place the micropay order and wait with DefaultMicropayPolicy, see MicropayWithPolicy
+func (this *AppTrans) MicropayAndWait(params map[string]string) (*MicropayResult, error) {
+	return this.MicropayWithPolicy(context.Background(), params, DefaultMicropayPolicy)
+}
+
+// MicropayWithPolicy place the micropay order and block until the outcome is definitive: result is
+// returned only if the order is paid, otherwise *MicropayError is returned once the order failed or
+// is reversed. Transport failure, http status other than 200 and sign mismatch leave the outcome unknown
+// and are handled as pending like USERPAYING, the user may have been charged. Other errors, e.g. *ResultError
+// of a rejected order, return_code FAIL or *OverloadedError, are returned at once as the order is not placed.
+// If ctx is done while waiting, the order is reversed as on timeout.
+func (this *AppTrans) MicropayWithPolicy(ctx context.Context, params map[string]string, policy MicropayPolicy) (*MicropayResult, error) {
+	result, err := this.micropay(ctx, params)
+	if err == nil {
+		return result, nil
+	}
+
+	if !isMicropayUnknown(err) {
+		return nil, err
+	}
+
+	outTradeNo := params["out_trade_no"]
+	deadline := time.Now().Add(policy.Timeout)
+	for attempt := 1; policy.Poll.Allow(attempt) && time.Now().Before(deadline); attempt++ {
+		if err := policy.Poll.Wait(ctx, attempt); err != nil {
+			break
+		}
+
+		queryResult, err := this.queryOrder(ctx, map[string]string{"out_trade_no": outTradeNo})
+		if err != nil || queryResult.ResultCode != "SUCCESS" {
+			continue
+		}
+
+		switch queryResult.TradeState {
+		case "SUCCESS":
+			return micropayResultOf(&queryResult), nil
+		case "USERPAYING", "NOTPAY":
+			continue
+		default:
+			return nil, &MicropayError{OutTradeNo: outTradeNo, TradeState: queryResult.TradeState, TradeStateDesc: queryResult.TradeStateDesc}
+		}
+	}
+
+	// the order is reversed even if ctx is done, otherwise the user may be charged for nothing
+	if _, err := this.ReverseContext(context.WithoutCancel(ctx), outTradeNo); err != nil {
+		return nil, &MicropayError{OutTradeNo: outTradeNo, ReverseErr: err}
+	}
+	return nil, &MicropayError{OutTradeNo: outTradeNo, Reversed: true}
+}
+
+// isMicropayUnknown tell whether the micropay order may be placed though err is returned, so it should be queried
+func isMicropayUnknown(err error) bool {
+	if resultErr, ok := err.(*ResultError); ok {
+		return isMicropayPending(resultErr.ErrCode)
+	}
+	return isOutcomeUnknown(err)
+}
+
+// isMicropayPending tell whether the micropay result is unknown and the order should be queried
+func isMicropayPending(errCode string) bool {
+	return errCode == "USERPAYING" || errCode == "SYSTEMERROR" || errCode == "BANKERROR"
+}
+
+// micropayResultOf convert the query result of a paid micropay order to MicropayResult
+func micropayResultOf(queryResult *QueryOrderResult) *MicropayResult {
+	return &MicropayResult{
+		ReturnCode:         queryResult.ReturnCode,
+		ReturnMsg:          queryResult.ReturnMsg,
+		AppId:              queryResult.AppId,
+		MchId:              queryResult.MchId,
+		DeviceInfo:         queryResult.DeviceInfo,
+		NonceStr:           queryResult.NonceStr,
+		Sign:               queryResult.Sign,
+		ResultCode:         queryResult.ResultCode,
+		OpenId:             queryResult.OpenId,
+		IsSubscribe:        queryResult.IsSubscribe,
+		TradeType:          queryResult.TradeType,
+		BankType:           queryResult.BankType,
+		FeeType:            queryResult.FeeType,
+		TotalFee:           queryResult.TotalFee,
+		SettlementTotalFee: queryResult.SettlementTotalFee,
+		CouponFee:          queryResult.CouponFee,
+		CashFeeType:        queryResult.CashFeeType,
+		CashFee:            queryResult.CashFee,
+		TransactionId:      queryResult.TransactionId,
+		OutTradeNo:         queryResult.OrderId,
+		Attach:             queryResult.Attach,
+		TimeEnd:            queryResult.TimeEnd,
+		PromotionDetail:    queryResult.PromotionDetail,
+		Envelope:           queryResult.Envelope,
+	}
+}
+
+// AuthCodeCacheTTL is how long the openid of an auth_code is cached, auth_code expire in about one minute
+const AuthCodeCacheTTL = time.Minute
+
+// AuthCodeToOpenId return the openid of user by the auth_code scanned from user's barcode.
+// The result is cached in WxConfig.AuthCodeCache if it is set.
+func (this *AppTrans) AuthCodeToOpenId(authCode string) (string, error) {
+	cache := this.Config.AuthCodeCache
+	if cache != nil {
+		if openId, ok := cache.Get(authCode); ok {
+			return openId, nil
+		}
+	}
+
+	param := this.newParam()
+	param["auth_code"] = authCode
+
+	_, resp, envelope, err := this.call(context.Background(), Endpoints[PathAuthCodeToOpenId], param)
+	if err != nil {
+		return "", err
+	}
+	if err := resultError(resp, envelope); err != nil {
+		return "", err
+	}
+
+	openId := resp["openid"]
+	if cache != nil {
+		cache.Set(authCode, openId, AuthCodeCacheTTL)
+	}
+	return openId, nil
+}
+
+// Terminal describe the POS terminal placing micropay orders, which is used by weixin pay for risk control
+type Terminal struct {
+	// DeviceInfo is the terminal serial number
+	DeviceInfo string
+	Store      StoreInfo
+}
+
+// Apply set device_info and scene_info of the terminal to the micropay params
+func (this *Terminal) Apply(params map[string]string) error {
+	setIfNotEmpty(params, "device_info", this.DeviceInfo)
+	if this.Store.Id == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(&SceneInfo{StoreInfo: &this.Store})
+	if err != nil {
+		return err
+	}
+	params["scene_info"] = string(data)
+	return nil
+}
+
+// MicropayAtTerminal is like Micropay but the terminal metadata is applied to params
+func (this *AppTrans) MicropayAtTerminal(terminal *Terminal, params map[string]string) (*MicropayResult, error) {
+	param := make(map[string]string)
+	for k, v := range params {
+		param[k] = v
+	}
+	if err := terminal.Apply(param); err != nil {
+		return nil, err
+	}
+
+	return this.Micropay(param)
+}
@@ -0,0 +1,181 @@
+package wxpay
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCalls count the requests to the fake gateway by api path
+type testCalls struct {
+	mu    sync.Mutex
+	count map[string]int
+}
+
+func (this *testCalls) add(path string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.count == nil {
+		this.count = make(map[string]int)
+	}
+	this.count[path]++
+}
+
+func (this *testCalls) get(path string) int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.count[path]
+}
+
+var testMicropayPolicy = MicropayPolicy{Poll: Backoff{Initial: time.Millisecond, MaxAttempts: 3}, Timeout: time.Second}
+
+func testMicropayParams() map[string]string {
+	return map[string]string{"body": "test", "out_trade_no": "T1", "total_fee": "1", "spbill_create_ip": "127.0.0.1", "auth_code": "134567890123456789"}
+}
+
+// replyMicropay answer micropay with micropay, order query with tradeState and reverse with SUCCESS
+func replyMicropay(calls *testCalls, micropay map[string]string, tradeState string) func(string, map[string]string) string {
+	return func(path string, param map[string]string) string {
+		calls.add(path)
+		switch path {
+		case PathMicropay:
+			return signedXml(micropay)
+		case PathOrderQuery:
+			return signedXml(map[string]string{
+				"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100",
+				"out_trade_no": param["out_trade_no"], "trade_state": tradeState, "transaction_id": "4200001", "total_fee": "1",
+			})
+		case PathReverse:
+			return signedXml(map[string]string{"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100", "recall": "N"})
+		}
+		return ""
+	}
+}
+
+func TestMicropayUserPaying(t *testing.T) {
+	calls := &testCalls{}
+	trans := newTestGateway(t, replyMicropay(calls, map[string]string{
+		"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "USERPAYING", "appid": "wx1", "mch_id": "100",
+	}, "SUCCESS"))
+
+	result, err := trans.MicropayWithPolicy(context.Background(), testMicropayParams(), testMicropayPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TransactionId != "4200001" || result.OutTradeNo != "T1" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if calls.get(PathReverse) != 0 {
+		t.Error("paid order is reversed")
+	}
+}
+
+func TestMicropayTimeoutReverse(t *testing.T) {
+	calls := &testCalls{}
+	trans := newTestGateway(t, replyMicropay(calls, map[string]string{
+		"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "USERPAYING", "appid": "wx1", "mch_id": "100",
+	}, "USERPAYING"))
+
+	_, err := trans.MicropayWithPolicy(context.Background(), testMicropayParams(), testMicropayPolicy)
+	micropayErr, ok := err.(*MicropayError)
+	if !ok || !micropayErr.Reversed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.get(PathOrderQuery) != 3 || calls.get(PathReverse) != 1 {
+		t.Errorf("got %d queries and %d reverses", calls.get(PathOrderQuery), calls.get(PathReverse))
+	}
+}
+
+func TestMicropayReturnFail(t *testing.T) {
+	calls := &testCalls{}
+	trans := newTestGateway(t, replyMicropay(calls, map[string]string{"return_code": "FAIL", "return_msg": "sign error"}, "USERPAYING"))
+
+	_, err := trans.MicropayWithPolicy(context.Background(), testMicropayParams(), testMicropayPolicy)
+	if _, ok := err.(*MicropayError); err == nil || ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.get(PathOrderQuery) != 0 || calls.get(PathReverse) != 0 {
+		t.Errorf("rejected order is queried %d times and reversed %d times", calls.get(PathOrderQuery), calls.get(PathReverse))
+	}
+}
+
+func TestMicropayOverloaded(t *testing.T) {
+	calls := &testCalls{}
+	trans := newTestGateway(t, replyMicropay(calls, nil, "USERPAYING"))
+	trans.Config.MchId = "micropay-overloaded"
+	trans.Config.MaxInFlight = 1
+	release, err := trans.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	_, err = trans.MicropayWithPolicy(context.Background(), testMicropayParams(), testMicropayPolicy)
+	if _, ok := err.(*OverloadedError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.get(PathOrderQuery) != 0 || calls.get(PathReverse) != 0 {
+		t.Error("order not sent is queried or reversed")
+	}
+}
+
+// replyReverse answer the reverse attempts in order, the last reply is repeated, an empty reply abort the connection
+func replyReverse(calls *testCalls, recalls ...string) func(string, map[string]string) string {
+	return func(path string, param map[string]string) string {
+		calls.add(path)
+		recall := recalls[len(recalls)-1]
+		if attempt := calls.get(path); attempt <= len(recalls) {
+			recall = recalls[attempt-1]
+		}
+		if recall == "" {
+			panic(http.ErrAbortHandler)
+		}
+		return signedXml(map[string]string{"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100", "recall": recall})
+	}
+}
+
+func withReverseBackoff(t *testing.T, backoff Backoff) {
+	saved := ReverseBackoff
+	ReverseBackoff = backoff
+	t.Cleanup(func() { ReverseBackoff = saved })
+}
+
+func TestReverseRecall(t *testing.T) {
+	withReverseBackoff(t, Backoff{Initial: time.Millisecond, MaxAttempts: 5})
+	cases := map[string][]string{
+		"recall":    {"Y", "Y", "N"},
+		"transport": {"", "N"},
+		"mixed":     {"", "Y", "N"},
+	}
+	for name, recalls := range cases {
+		calls := &testCalls{}
+		trans := newTestGateway(t, replyReverse(calls, recalls...))
+
+		result, err := trans.ReverseContext(context.Background(), "T1")
+		if err != nil || result.Recall != "N" {
+			t.Errorf("%s: unexpected result %+v, %v", name, result, err)
+		}
+		if calls.get(PathReverse) != len(recalls) {
+			t.Errorf("%s: reversed %d times", name, calls.get(PathReverse))
+		}
+	}
+}
+
+func TestReverseGiveUp(t *testing.T) {
+	withReverseBackoff(t, Backoff{Initial: time.Millisecond, MaxAttempts: 3})
+	for _, recall := range []string{"Y", ""} {
+		calls := &testCalls{}
+		trans := newTestGateway(t, replyReverse(calls, recall))
+
+		_, err := trans.ReverseContext(context.Background(), "T1")
+		if err == nil || !strings.Contains(err.Error(), "not reversed after 3 attempts") {
+			t.Errorf("recall %q: unexpected error %v", recall, err)
+		}
+		if calls.get(PathReverse) != 3 {
+			t.Errorf("recall %q: reversed %d times", recall, calls.get(PathReverse))
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package wxpay
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fen is amount of CNY in fen(分), the unit of amounts in weixin pay
+type Fen int64
+
+// ParseFen parse the amount field of weixin pay, empty string is parsed as 0
+func ParseFen(s string) (Fen, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	fen, err := strconv.ParseInt(s, 10, 64)
+	return Fen(fen), err
+}
+
+// ParseYuan parse the amount in yuan with at most two decimals, e.g. "12.34" in bills.
+// Empty string is parsed as 0.
+func ParseYuan(s string) (Fen, error) {
+	fen, err := parseDecimal(s, 2)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount in yuan:%s", s)
+	}
+	return Fen(fen), nil
+}
+
+// parseDecimal parse the decimal s with at most scale decimals into integer in unit of 10^-scale
+func parseDecimal(s string, scale int) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	digits := strings.TrimPrefix(s, "-")
+	integer, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		integer, fraction = digits[:i], digits[i+1:]
+	}
+	if integer == "" || len(fraction) > scale || strings.ContainsAny(integer+fraction, "+-") {
+		return 0, fmt.Errorf("invalid decimal:%s", s)
+	}
+	for len(fraction) < scale {
+		fraction += "0"
+	}
+
+	value, err := strconv.ParseInt(integer+fraction, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if digits != s {
+		value = -value
+	}
+	return value, nil
+}
+
+// Poundage is the poundage(手续费) in bills, which has five decimals in yuan(e.g. "0.00600"),
+// so it is kept in unit of 0.00001 yuan, a thousandth of fen
+type Poundage int64
+
+// PoundagePerFen is the number of Poundage units in a fen
+const PoundagePerFen = 1000
+
+// ParsePoundage parse the poundage in yuan with at most five decimals, empty string is parsed as 0
+func ParsePoundage(s string) (Poundage, error) {
+	poundage, err := parseDecimal(s, 5)
+	if err != nil {
+		return 0, fmt.Errorf("invalid poundage in yuan:%s", s)
+	}
+	return Poundage(poundage), nil
+}
+
+// Fen round the poundage to fen, half away from zero
+func (this Poundage) Fen() Fen {
+	if this < 0 {
+		return -(-this).Fen()
+	}
+	return Fen((this + PoundagePerFen/2) / PoundagePerFen)
+}
+
+// String format the poundage in yuan with five decimals, e.g. "0.00600"
+func (this Poundage) String() string {
+	sign := ""
+	digits := strconv.FormatInt(int64(this), 10)
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	for len(digits) < 6 {
+		digits = "0" + digits
+	}
+	return sign + digits[:len(digits)-5] + "." + digits[len(digits)-5:]
+}
+
+// String format the amount in yuan with two decimals, e.g. "12.34" or "-0.05"
+func (this Fen) String() string {
+	return this.format("", false)
+}
+
+// Format format the amount for display with thousand separators according to locale:
+// "zh" gives "¥1,234.56", "en" gives "CNY 1,234.56", refunds(negative) are prefixed with "-".
+// Unknown locales are treated as "zh".
+func (this Fen) Format(locale string) string {
+	if locale == "en" {
+		return this.format("CNY ", true)
+	}
+	return this.format("¥", true)
+}
+
+func (this Fen) format(symbol string, separate bool) string {
+	sign := ""
+	fen := int64(this)
+	if fen < 0 {
+		sign = "-"
+	}
+
+	// -fen overflows for the min int64, so the digits are taken from the string form
+	digits := strconv.FormatInt(fen, 10)
+	digits = strings.TrimPrefix(digits, "-")
+	for len(digits) < 3 {
+		digits = "0" + digits
+	}
+	yuan, cents := digits[:len(digits)-2], digits[len(digits)-2:]
+
+	if separate {
+		var buf strings.Builder
+		for i, c := range yuan {
+			if i > 0 && (len(yuan)-i)%3 == 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteRune(c)
+		}
+		yuan = buf.String()
+	}
+
+	return sign + symbol + yuan + "." + cents
+}
+
+// OrderAmounts is the amounts of a paid order.
+// SettlementTotalFee is total fee minus non-recharge coupons, or zero if no coupon is applied.
+type OrderAmounts struct {
+	TotalFee           Fen
+	SettlementTotalFee Fen
+	CashFee            Fen
+	CouponFee          Fen
+}
+
+// EffectiveAmount return the amount should be used for reconciliation,
+// settlement_total_fee if it is returned, or total_fee otherwise
+func (this OrderAmounts) EffectiveAmount() Fen {
+	if this.SettlementTotalFee > 0 {
+		return this.SettlementTotalFee
+	}
+	return this.TotalFee
+}
+
+// parseOrderAmounts parse the amount fields in form of string
+func parseOrderAmounts(totalFee, settlementTotalFee, cashFee, couponFee string) (OrderAmounts, error) {
+	var amounts OrderAmounts
+	var err error
+	if amounts.TotalFee, err = ParseFen(totalFee); err != nil {
+		return amounts, err
+	}
+	if amounts.SettlementTotalFee, err = ParseFen(settlementTotalFee); err != nil {
+		return amounts, err
+	}
+	if amounts.CashFee, err = ParseFen(cashFee); err != nil {
+		return amounts, err
+	}
+	if amounts.CouponFee, err = ParseFen(couponFee); err != nil {
+		return amounts, err
+	}
+	return amounts, nil
+}
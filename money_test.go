@@ -0,0 +1,49 @@
+package wxpay
+
+import "testing"
+
+func TestParseYuan(t *testing.T) {
+	cases := map[string]Fen{"": 0, "0": 0, "12.34": 1234, "12.3": 1230, "-0.05": -5, "1": 100}
+	for s, want := range cases {
+		if got, err := ParseYuan(s); err != nil || got != want {
+			t.Errorf("ParseYuan(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"0.001", "abc", "1.-2", ".5", "--1"} {
+		if _, err := ParseYuan(s); err == nil {
+			t.Errorf("ParseYuan(%q) want error", s)
+		}
+	}
+}
+
+func TestParsePoundage(t *testing.T) {
+	cases := map[string]Poundage{"": 0, "0.00600": 600, "-0.00300": -300, "1.5": 150000}
+	for s, want := range cases {
+		got, err := ParsePoundage(s)
+		if err != nil || got != want {
+			t.Errorf("ParsePoundage(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	if _, err := ParsePoundage("0.000001"); err == nil {
+		t.Error("want error for six decimals")
+	}
+
+	rounding := map[Poundage]Fen{600: 1, 499: 0, 500: 1, -500: -1, -499: 0}
+	for poundage, want := range rounding {
+		if got := poundage.Fen(); got != want {
+			t.Errorf("Poundage(%d).Fen() = %d, want %d", poundage, got, want)
+		}
+	}
+	if s := Poundage(-300).String(); s != "-0.00300" {
+		t.Errorf("got %s", s)
+	}
+}
+
+func TestFenFormat(t *testing.T) {
+	if s := Fen(123456).Format("zh"); s != "¥1,234.56" {
+		t.Errorf("got %s", s)
+	}
+	if s := Fen(-5).Format("en"); s != "-CNY 0.05" {
+		t.Errorf("got %s", s)
+	}
+}
@@ -0,0 +1,48 @@
+package wxpay
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// SceneInfo is the scene_info of order, serialized to json string
+type SceneInfo struct {
+	H5Info    *H5Info    `json:"h5_info,omitempty"`
+	StoreInfo *StoreInfo `json:"store_info,omitempty"`
+}
+
+// StoreInfo describe the store of offline payment like micropay
+type StoreInfo struct {
+	Id       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	AreaCode string `json:"area_code,omitempty"`
+	Address  string `json:"address,omitempty"`
+}
+
+// H5Info describe the site or app starting the H5(MWEB) payment.
+// Type is "Wap" with WapUrl and WapName, "IOS" with AppName and BundleId, or "Android" with AppName and PackageName.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/H5.php?chapter=9_20&index=1
+type H5Info struct {
+	Type        string `json:"type"`
+	AppName     string `json:"app_name,omitempty"`
+	BundleId    string `json:"bundle_id,omitempty"`
+	PackageName string `json:"package_name,omitempty"`
+	WapUrl      string `json:"wap_url,omitempty"`
+	WapName     string `json:"wap_name,omitempty"`
+}
+
+// SetSceneInfo set SceneInfo of the order request in json
+func (this *OrderRequest) SetSceneInfo(sceneInfo *SceneInfo) error {
+	data, err := json.Marshal(sceneInfo)
+	if err != nil {
+		return err
+	}
+	this.SceneInfo = string(data)
+	return nil
+}
+
+// MwebUrlWithRedirect append redirect_url to mweb_url, user is redirected to it after the payment.
+// The domain of redirectUrl must be the one configured for H5 payment.
+func MwebUrlWithRedirect(mwebUrl string, redirectUrl string) string {
+	return mwebUrl + "&redirect_url=" + url.QueryEscape(redirectUrl)
+}
@@ -0,0 +1,117 @@
+package wxpay
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// NativeCallback represent the callback message weixin pay send to merchant when
+// user scan a qrcode of Native mode 1(扫码模式一).
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/native.php?chapter=6_4
+type NativeCallback struct {
+	XMLName     xml.Name `xml:"xml"`
+	AppId       string   `xml:"appid"`
+	OpenId      string   `xml:"openid"`
+	MchId       string   `xml:"mch_id"`
+	IsSubscribe string   `xml:"is_subscribe"`
+	NonceStr    string   `xml:"nonce_str"`
+	ProductId   string   `xml:"product_id"`
+	Sign        string   `xml:"sign"`
+}
+
+// NewNativeQrUrl build the url of mode 1 qrcode for the product, the url should be encoded as qrcode for user to scan
+func (this *AppTrans) NewNativeQrUrl(productId string) (string, error) {
+	key, err := this.appKey()
+	if err != nil {
+		return "", err
+	}
+
+	param := make(map[string]string)
+	param["appid"] = this.Config.AppId
+	param["mch_id"] = this.Config.MchId
+	param["product_id"] = productId
+	param["time_stamp"] = NewTimestampString()
+	param["nonce_str"] = NewNonceString()
+	param["sign"] = Sign(param, key)
+
+	query := url.Values{}
+	for k, v := range param {
+		query.Set(k, v)
+	}
+	return "weixin://wxpay/bizpayurl?" + query.Encode(), nil
+}
+
+// NativeCallbackHandler return the http handler for the callback of Native mode 1.
+// The callback is verified, then placeOrder is called to place the order of the product
+// (usually with Submit, using the openid of callback) and return the prepay id,
+// which is signed and written back to weixin pay. If placeOrder return error, the error is
+// reported to user as err_code_des.
+func (this *AppTrans) NativeCallbackHandler(placeOrder func(NativeCallback) (string, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			writeXmlResponse(w, returnFail(err.Error()))
+			return
+		}
+
+		callbackInMap, err := this.parseXmlMap(body)
+		if err != nil {
+			writeXmlResponse(w, returnFail(err.Error()))
+			return
+		}
+		if err := this.verifyNotifySign(SignSourceNativeCallback, callbackInMap); err != nil {
+			writeXmlResponse(w, returnFail(err.Error()))
+			return
+		}
+		if err := this.checkNotifyMerchant(callbackInMap); err != nil {
+			writeXmlResponse(w, returnFail(err.Error()))
+			return
+		}
+
+		callback := NativeCallback{}
+		if err := this.unmarshalXml(body, &callback); err != nil {
+			writeXmlResponse(w, returnFail(err.Error()))
+			return
+		}
+
+		param := make(map[string]string)
+		param["return_code"] = "SUCCESS"
+		param["appid"] = this.Config.AppId
+		param["mch_id"] = this.Config.MchId
+		param["nonce_str"] = NewNonceString()
+
+		prepayId := ""
+		err = this.safeCall(func() (err error) {
+			prepayId, err = placeOrder(callback)
+			return err
+		})
+		if err != nil {
+			param["result_code"] = "FAIL"
+			param["err_code_des"] = err.Error()
+		} else {
+			param["result_code"] = "SUCCESS"
+			param["prepay_id"] = prepayId
+		}
+		sign, err := this.sign(param)
+		if err != nil {
+			writeXmlResponse(w, returnFail(err.Error()))
+			return
+		}
+		param["sign"] = sign
+
+		writeXmlResponse(w, param)
+	})
+}
+
+// returnFail build the response telling weixin pay the message is not accepted
+func returnFail(msg string) map[string]string {
+	return map[string]string{"return_code": "FAIL", "return_msg": msg}
+}
+
+// writeXmlResponse write the parameter as xml to weixin pay
+func writeXmlResponse(w http.ResponseWriter, param map[string]string) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write([]byte(ToXmlString(param)))
+}
@@ -0,0 +1,64 @@
+package wxpay
+
+import (
+	"fmt"
+	"time"
+)
+
+// MinNativeQrTTL is the shortest expiry accepted by weixin pay for time_expire
+const MinNativeQrTTL = 5 * time.Minute
+
+// NativeQr is the qrcode of a Native order which expire at ExpireAt
+type NativeQr struct {
+	OutTradeNo string
+	CodeUrl    string
+	ExpireAt   time.Time
+}
+
+// Expired tell whether the qrcode cannot be paid any more
+func (this *NativeQr) Expired() bool {
+	return !time.Now().Before(this.ExpireAt)
+}
+
+// NewNativeQr place the Native order(mode 2) with time_expire set to ttl later(at least MinNativeQrTTL),
+// and return the code_url to show as qrcode with its expiry. TradeType of config must be NATIVE.
+func (this *AppTrans) NewNativeQr(req *OrderRequest, ttl time.Duration) (*NativeQr, error) {
+	if this.Config.TradeType != TradeTypeNative {
+		return nil, fmt.Errorf("trade type of config is %s, not %s", this.Config.TradeType, TradeTypeNative)
+	}
+	if ttl < MinNativeQrTTL {
+		return nil, fmt.Errorf("ttl %s is shorter than %s", ttl, MinNativeQrTTL)
+	}
+
+	order := *req
+	now := time.Now().In(ChinaTimeZone)
+	expireAt := now.Add(ttl)
+	order.TimeStart = now.Format(timeEndLayout)
+	order.TimeExpire = expireAt.Format(timeEndLayout)
+
+	result, err := this.SubmitOrder(&order)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NativeQr{OutTradeNo: order.OutTradeNo, CodeUrl: result.CodeUrl, ExpireAt: expireAt}, nil
+}
+
+// RefreshNativeQr return qr as is if it has not expired. Otherwise the expired order is closed and
+// a fresh qrcode is issued for req with newOutTradeNo, as order number cannot be reused after close.
+// If the expired order turns out paid, *ResultError with ErrCode ORDERPAID is returned.
+func (this *AppTrans) RefreshNativeQr(qr *NativeQr, req *OrderRequest, ttl time.Duration, newOutTradeNo string) (*NativeQr, error) {
+	if !qr.Expired() {
+		return qr, nil
+	}
+
+	if _, err := this.CloseOrder(qr.OutTradeNo); err != nil {
+		if resultErr, ok := err.(*ResultError); !ok || resultErr.ErrCode != "ORDERCLOSED" {
+			return nil, err
+		}
+	}
+
+	order := *req
+	order.OutTradeNo = newOutTradeNo
+	return this.NewNativeQr(&order, ttl)
+}
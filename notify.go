@@ -0,0 +1,139 @@
+package wxpay
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// PaymentNotification represent the payment result notification sent by weixin pay to notify_url.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=9_7&index=3
+type PaymentNotification struct {
+	XMLName            xml.Name `xml:"xml"`
+	ReturnCode         string   `xml:"return_code"`
+	ReturnMsg          string   `xml:"return_msg"`
+	AppId              string   `xml:"appid"`
+	MchId              string   `xml:"mch_id"`
+	DeviceInfo         string   `xml:"device_info"`
+	NonceStr           string   `xml:"nonce_str"`
+	Sign               string   `xml:"sign"`
+	SignType           string   `xml:"sign_type"`
+	ResultCode         string   `xml:"result_code"`
+	ErrCode            string   `xml:"err_code"`
+	ErrCodeDesc        string   `xml:"err_code_des"`
+	OpenId             string   `xml:"openid"`
+	IsSubscribe        string   `xml:"is_subscribe"`
+	TradeType          string   `xml:"trade_type"`
+	BankType           string   `xml:"bank_type"`
+	TotalFee           string   `xml:"total_fee"`
+	SettlementTotalFee string   `xml:"settlement_total_fee"`
+	FeeType            string   `xml:"fee_type"`
+	CashFee            string   `xml:"cash_fee"`
+	CashFeeType        string   `xml:"cash_fee_type"`
+	CouponFee          string   `xml:"coupon_fee"`
+	CouponCount        string   `xml:"coupon_count"`
+	TransactionId      string   `xml:"transaction_id"`
+	OutTradeNo         string   `xml:"out_trade_no"`
+	Attach             string   `xml:"attach"`
+	TimeEnd            string   `xml:"time_end"`
+	PromotionDetail    string   `xml:"promotion_detail"`
+}
+
+// BankName return the human readable name of bank_type
+func (this *PaymentNotification) BankName() string {
+	return BankName(this.BankType)
+}
+
+// Amounts return the typed amounts of the order
+func (this *PaymentNotification) Amounts() (OrderAmounts, error) {
+	return parseOrderAmounts(this.TotalFee, this.SettlementTotalFee, this.CashFee, this.CouponFee)
+}
+
+// Promotions return the parsed promotion_detail of the order
+func (this *PaymentNotification) Promotions() ([]PromotionDetail, error) {
+	return ParsePromotionDetail(this.PromotionDetail)
+}
+
+// ParseNotify parse the payment notification in body of the request to notify_url, and verify its sign
+// and merchant. If the payment failed, the notification is returned with *ResultError.
+func (this *AppTrans) ParseNotify(body []byte) (*PaymentNotification, error) {
+	notifyInMap, err := this.parseXmlMap(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if notifyInMap["return_code"] != "SUCCESS" {
+		return nil, fmt.Errorf("return code:%s, return desc:%s", notifyInMap["return_code"], notifyInMap["return_msg"])
+	}
+
+	if err := this.verifyNotifySign(SignSourceNotify, notifyInMap); err != nil {
+		return nil, err
+	}
+
+	if err := this.checkNotifyMerchant(notifyInMap); err != nil {
+		return nil, err
+	}
+
+	notification := PaymentNotification{}
+	if err := this.unmarshalXml(body, &notification); err != nil {
+		return nil, err
+	}
+
+	if err := resultError(notifyInMap, nil); err != nil {
+		return &notification, err
+	}
+	return &notification, nil
+}
+
+// notifyHandleFailed is the return_msg replied when the handle of notification failed, the error itself
+// is only logged as it may contain internal details
+const notifyHandleFailed = "notification not handled"
+
+// NotifyHandler return the http handler for notify_url. The notification is parsed and verified with
+// ParseNotify, then handle is called with it, including notifications of failed payment, check
+// ResultCode for them. SUCCESS is replied if handle return nil, otherwise the error is logged and FAIL
+// is replied so weixin pay will notify again later. Panic in handle is recovered and replied with FAIL.
+// Note the same notification may be sent more than once, handle should be idempotent. Set
+// WxConfig.ReplayGuard to answer duplicates without calling handle.
+func (this *AppTrans) NotifyHandler(handle func(PaymentNotification) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			writeXmlResponse(w, returnFail(err.Error()))
+			return
+		}
+
+		notification, err := this.ParseNotify(body)
+		if _, ok := err.(*ResultError); err != nil && !ok {
+			writeXmlResponse(w, returnFail(err.Error()))
+			return
+		}
+
+		guard := this.Config.ReplayGuard
+		notifyInMap, _ := ToMap(notification)
+		if guard != nil {
+			if err := guard.Check(notifyInMap); err != nil {
+				if replayErr, ok := err.(*ReplayError); ok && replayErr.Duplicate {
+					writeXmlResponse(w, map[string]string{"return_code": "SUCCESS", "return_msg": "OK"})
+					return
+				}
+				writeXmlResponse(w, returnFail(err.Error()))
+				return
+			}
+		}
+
+		if err := this.safeCall(func() error { return handle(*notification) }); err != nil {
+			this.logf("wxpay: notification handler failed for %s: %v", notification.OutTradeNo, err)
+			if guard != nil {
+				if err := guard.Forget(notifyInMap); err != nil {
+					this.logf("wxpay: failed to forget notification of %s: %v", notification.OutTradeNo, err)
+				}
+			}
+			writeXmlResponse(w, returnFail(notifyHandleFailed))
+			return
+		}
+
+		writeXmlResponse(w, map[string]string{"return_code": "SUCCESS", "return_msg": "OK"})
+	})
+}
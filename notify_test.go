@@ -0,0 +1,287 @@
+package wxpay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type discardLogger struct{}
+
+func (this discardLogger) Printf(format string, v ...interface{}) {}
+
+// recordLogger keep the logged messages
+type recordLogger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (this *recordLogger) Printf(format string, v ...interface{}) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.logs = append(this.logs, fmt.Sprintf(format, v...))
+}
+
+func (this *recordLogger) String() string {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return strings.Join(this.logs, "\n")
+}
+
+func newTestNotify(t *testing.T, trans *AppTrans) string {
+	param := map[string]string{
+		"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": trans.Config.AppId, "mch_id": trans.Config.MchId,
+		"out_trade_no": "T1", "transaction_id": "4200001", "total_fee": "1",
+	}
+	param["sign"] = Sign(param, trans.Config.AppKey)
+	return ToXmlString(param)
+}
+
+func postNotify(handler http.Handler, body string) string {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/notify", strings.NewReader(body)))
+	return recorder.Body.String()
+}
+
+func TestNotifyFanOut(t *testing.T) {
+	trans := &AppTrans{Config: &WxConfig{AppId: "wx1", MchId: "100", AppKey: signExampleKey, Logger: discardLogger{}}}
+	var called []string
+	consumer := func(name string, err error, critical bool) NotifyConsumer {
+		return NotifyConsumer{Name: name, Critical: critical, Handle: func(n PaymentNotification) error {
+			called = append(called, name+":"+n.OutTradeNo)
+			if name == "panic" {
+				panic("boom")
+			}
+			return err
+		}}
+	}
+
+	handler := trans.NotifyHandler(trans.NotifyFanOut(
+		consumer("ledger", nil, true),
+		consumer("analytics", errors.New("down"), false),
+		consumer("panic", nil, false),
+		consumer("crm", nil, false),
+	))
+	if reply := postNotify(handler, newTestNotify(t, trans)); !strings.Contains(reply, "SUCCESS") {
+		t.Errorf("non-critical failures change the reply: %s", reply)
+	}
+	if strings.Join(called, ",") != "ledger:T1,analytics:T1,panic:T1,crm:T1" {
+		t.Errorf("unexpected calls: %v", called)
+	}
+
+	handler = trans.NotifyHandler(trans.NotifyFanOut(consumer("ledger", errors.New("db down"), true), consumer("crm", nil, false)))
+	if reply := postNotify(handler, newTestNotify(t, trans)); !strings.Contains(reply, "FAIL") || strings.Contains(reply, "db down") {
+		t.Errorf("critical failure is not replied, or its detail is exposed: %s", reply)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	trans := &AppTrans{Config: &WxConfig{AppId: "wx1", MchId: "100", AppKey: signExampleKey, Logger: discardLogger{}}}
+	outbox := NewMemoryOutbox()
+	handler := trans.NotifyHandler(trans.OutboxHandle(outbox))
+	for i := 0; i < 2; i++ {
+		if reply := postNotify(handler, newTestNotify(t, trans)); !strings.Contains(reply, "SUCCESS") {
+			t.Fatalf("unexpected reply: %s", reply)
+		}
+	}
+
+	var published []OutboxEvent
+	fail := true
+	dispatcher := &OutboxDispatcher{Store: outbox, Publish: func(event OutboxEvent) error {
+		if fail {
+			fail = false
+			return errors.New("broker down")
+		}
+		published = append(published, event)
+		return nil
+	}, Retry: Backoff{Initial: time.Millisecond}}
+	if _, err := dispatcher.DispatchOnce(); err == nil {
+		t.Error("want error of publish")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if n, err := dispatcher.DispatchOnce(); err != nil || n != 1 {
+		t.Errorf("published %d events, err: %v", n, err)
+	}
+	if len(published) != 1 || published[0].Id != "4200001" {
+		t.Errorf("notification sent twice is not credited once: %+v", published)
+	}
+	if n, _ := dispatcher.DispatchOnce(); n != 0 {
+		t.Errorf("published event is dispatched again")
+	}
+}
+
+func TestNotifyHandlerReplayGuard(t *testing.T) {
+	trans := &AppTrans{Config: &WxConfig{AppId: "wx1", MchId: "100", AppKey: signExampleKey, Logger: discardLogger{}}}
+	trans.Config.ReplayGuard = &ReplayGuard{Window: NotifyRetryPeriod + time.Hour, Store: NewMemoryNonceStore()}
+	notify := func(timeEnd time.Time) string {
+		param := map[string]string{
+			"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100", "nonce_str": "n1",
+			"out_trade_no": "T1", "transaction_id": "4200001", "total_fee": "1", "time_end": timeEnd.In(ChinaTimeZone).Format(timeEndLayout),
+		}
+		param["sign"] = Sign(param, signExampleKey)
+		return ToXmlString(param)
+	}
+
+	handled := 0
+	var handleErr error
+	handler := trans.NotifyHandler(func(PaymentNotification) error {
+		handled++
+		return handleErr
+	})
+
+	handleErr = errors.New("db down")
+	if reply := postNotify(handler, notify(time.Now())); !strings.Contains(reply, "FAIL") {
+		t.Errorf("failed handle is not replied with FAIL: %s", reply)
+	}
+	handleErr = nil
+	if reply := postNotify(handler, notify(time.Now())); !strings.Contains(reply, "SUCCESS") || handled != 2 {
+		t.Errorf("retry after failure is not handled: %s, handled %d", reply, handled)
+	}
+	if reply := postNotify(handler, notify(time.Now())); !strings.Contains(reply, "SUCCESS") || handled != 2 {
+		t.Errorf("duplicate is not answered without handling: %s, handled %d", reply, handled)
+	}
+
+	trans.Config.ReplayGuard.Store = NewMemoryNonceStore()
+	if reply := postNotify(handler, notify(time.Now().Add(-NotifyRetryPeriod))); !strings.Contains(reply, "SUCCESS") || handled != 3 {
+		t.Errorf("late retry within the window is rejected: %s", reply)
+	}
+	if reply := postNotify(handler, notify(time.Now().Add(-2*NotifyRetryPeriod))); !strings.Contains(reply, "FAIL") || handled != 3 {
+		t.Errorf("notification out of window is accepted: %s", reply)
+	}
+}
+
+func TestOutboxDeadLetter(t *testing.T) {
+	outbox := NewMemoryOutbox()
+	for _, id := range []string{"poison", "4200001", "4200002"} {
+		outbox.Credit(PaymentNotification{TransactionId: id}, OutboxEvent{Id: id})
+	}
+
+	var published, dead []string
+	dispatcher := &OutboxDispatcher{
+		Store: outbox,
+		Publish: func(event OutboxEvent) error {
+			if event.Id == "poison" {
+				return errors.New("rejected by broker")
+			}
+			published = append(published, event.Id)
+			return nil
+		},
+		Retry: Backoff{Initial: 50 * time.Millisecond, MaxAttempts: 2},
+		DeadLetter: func(event OutboxEvent, err error) error {
+			dead = append(dead, event.Id+": "+err.Error())
+			return nil
+		},
+	}
+
+	if n, err := dispatcher.DispatchOnce(); n != 2 || err == nil {
+		t.Errorf("published %d events, err: %v", n, err)
+	}
+	if strings.Join(published, ",") != "4200001,4200002" {
+		t.Errorf("failing event blocks the others: %v", published)
+	}
+	if n, err := dispatcher.DispatchOnce(); n != 0 || err != nil || len(dead) != 0 {
+		t.Errorf("failed event is retried before the delay, published %d events, err: %v", n, err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if n, err := dispatcher.DispatchOnce(); n != 0 || err != nil {
+		t.Errorf("published %d events, err: %v", n, err)
+	}
+	if len(dead) != 1 || dead[0] != "poison: rejected by broker" {
+		t.Errorf("unexpected dead letters: %v", dead)
+	}
+	if pending, _ := outbox.Pending(10); len(pending) != 0 {
+		t.Errorf("events are left pending: %+v", pending)
+	}
+}
+
+func TestOutboxDispatcherLocker(t *testing.T) {
+	outbox := NewMemoryOutbox()
+	locker := NewMemoryLocker()
+	var published []string
+	newDispatcher := func(owner string) *OutboxDispatcher {
+		return &OutboxDispatcher{Store: outbox, Locker: locker, LockOwner: owner, Publish: func(event OutboxEvent) error {
+			published = append(published, owner+":"+event.Id)
+			return nil
+		}}
+	}
+	first, second := newDispatcher("a"), newDispatcher("b")
+
+	outbox.Credit(PaymentNotification{}, OutboxEvent{Id: "4200001"})
+	if n, err := first.dispatchLocked(); n != 1 || err != nil {
+		t.Errorf("published %d events, err: %v", n, err)
+	}
+	outbox.Credit(PaymentNotification{}, OutboxEvent{Id: "4200002"})
+	if n, err := second.dispatchLocked(); n != 0 || err != nil {
+		t.Errorf("dispatcher without lock published %d events, err: %v", n, err)
+	}
+
+	first.unlock()
+	if n, err := second.dispatchLocked(); n != 1 || err != nil {
+		t.Errorf("published %d events after unlock, err: %v", n, err)
+	}
+	if strings.Join(published, ",") != "a:4200001,b:4200002" {
+		t.Errorf("unexpected publishing: %v", published)
+	}
+}
+
+func TestOutboxDispatcherShutdown(t *testing.T) {
+	outbox := NewMemoryOutbox()
+	published := make(chan string, 2)
+	dispatcher := &OutboxDispatcher{Store: outbox, Poll: Backoff{Initial: time.Hour}, Publish: func(event OutboxEvent) error {
+		published <- event.Id
+		return nil
+	}}
+
+	outbox.Credit(PaymentNotification{}, OutboxEvent{Id: "4200001"})
+	result := make(chan error, 1)
+	go func() { result <- dispatcher.Run(context.Background()) }()
+	<-published
+	outbox.Credit(PaymentNotification{}, OutboxEvent{Id: "4200002"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := dispatcher.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("Run return %v after shutdown", err)
+	}
+	if len(published) != 1 || <-published != "4200002" {
+		t.Error("pending event is not drained")
+	}
+	if err := dispatcher.Run(context.Background()); err != ErrDispatcherShutdown {
+		t.Errorf("Run after shutdown return %v", err)
+	}
+}
+
+func TestNotifyHandlerFailure(t *testing.T) {
+	logger := &recordLogger{}
+	trans := &AppTrans{Config: &WxConfig{AppId: "wx1", MchId: "100", AppKey: signExampleKey, Logger: logger}}
+	cases := map[string]func(PaymentNotification) error{
+		"panic": func(PaymentNotification) error { panic("nil order") },
+		"error": func(PaymentNotification) error { return errors.New("dial tcp 10.0.0.5:3306: connection refused") },
+	}
+	for name, handle := range cases {
+		recorder := httptest.NewRecorder()
+		trans.NotifyHandler(handle).ServeHTTP(recorder, httptest.NewRequest("POST", "/notify", strings.NewReader(newTestNotify(t, trans))))
+
+		reply, err := ParseXmlMap(recorder.Body.Bytes())
+		if recorder.Code != http.StatusOK || err != nil || reply["return_code"] != "FAIL" || reply["return_msg"] != notifyHandleFailed {
+			t.Errorf("%s: unexpected reply %d %s", name, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	logs := logger.String()
+	if !strings.Contains(logs, "panic in notification handler: nil order") || !strings.Contains(logs, "runtime/debug.Stack") {
+		t.Errorf("panic is not logged with stack: %s", logs)
+	}
+	if !strings.Contains(logs, "10.0.0.5:3306") {
+		t.Errorf("handler error is not logged: %s", logs)
+	}
+}
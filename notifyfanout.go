@@ -0,0 +1,41 @@
+package wxpay
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NotifyConsumer is an independent consumer of payment notifications, e.g. ledger, analytics or CRM
+type NotifyConsumer struct {
+	Name   string
+	Handle func(PaymentNotification) error
+	// Critical decide the reply to weixin pay: FAIL is replied if any critical consumer fails, so the
+	// notification is sent again. Failures of other consumers are only logged.
+	Critical bool
+}
+
+// NotifyFanOut return the handle for NotifyHandler calling every consumer in order with the notification.
+// Consumers are isolated, an error or panic of one does not stop the others. As the notification is sent
+// again while a critical consumer fails, all consumers should be idempotent.
+func (this *AppTrans) NotifyFanOut(consumers ...NotifyConsumer) func(PaymentNotification) error {
+	return func(notification PaymentNotification) error {
+		var failures []string
+		for _, consumer := range consumers {
+			handle := consumer.Handle
+			err := this.safeCall(func() error { return handle(notification) })
+			if err == nil {
+				continue
+			}
+			if consumer.Critical {
+				failures = append(failures, consumer.Name+": "+err.Error())
+				continue
+			}
+			this.logf("wxpay: notification consumer %s failed for %s: %v", consumer.Name, notification.OutTradeNo, err)
+		}
+
+		if len(failures) > 0 {
+			return fmt.Errorf("critical consumers failed, %s", strings.Join(failures, "; "))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package wxpay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+)
+
+const (
+	// OAuthScopeBase get the openid silently, it is enough for JSAPI payment
+	OAuthScopeBase = "snsapi_base"
+	// OAuthScopeUserInfo ask user to authorize, in addition to openid
+	OAuthScopeUserInfo = "snsapi_userinfo"
+)
+
+const (
+	oauthAuthorizeUrl   = "https://open.weixin.qq.com/connect/oauth2/authorize"
+	oauthAccessTokenUrl = "https://api.weixin.qq.com/sns/oauth2/access_token"
+)
+
+// OAuthError is returned when the oauth api of official account respond errcode
+type OAuthError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (this *OAuthError) Error() string {
+	return fmt.Sprintf("oauth errcode:%d, errmsg:%s", this.ErrCode, this.ErrMsg)
+}
+
+// BuildAuthorizeUrl build the url to redirect user to in weixin, weixin redirect user back to
+// redirectUri with code and state, then exchange the code with ExchangeCodeForOpenId.
+// The domain of redirectUri must be configured in the official account of AppId.
+// Refer to https://developers.weixin.qq.com/doc/offiaccount/OA_Web_Apps/Wechat_webpage_authorization.html
+func (this *AppTrans) BuildAuthorizeUrl(redirectUri, scope, state string) string {
+	if scope == "" {
+		scope = OAuthScopeBase
+	}
+
+	// weixin require the parameters in this order
+	return oauthAuthorizeUrl +
+		"?appid=" + url.QueryEscape(this.Config.AppId) +
+		"&redirect_uri=" + url.QueryEscape(redirectUri) +
+		"&response_type=code" +
+		"&scope=" + url.QueryEscape(scope) +
+		"&state=" + url.QueryEscape(state) +
+		"#wechat_redirect"
+}
+
+// ExchangeCodeForOpenId exchange the code from redirect of BuildAuthorizeUrl for the openid of user,
+// which can be used for JSAPI orders. WxConfig.AppSecret is required. A code can only be used once.
+func (this *AppTrans) ExchangeCodeForOpenId(code string) (string, error) {
+	if this.Config.AppSecret == "" {
+		return "", fmt.Errorf("AppSecret is required to exchange oauth code")
+	}
+
+	query := url.Values{}
+	query.Set("appid", this.Config.AppId)
+	query.Set("secret", this.Config.AppSecret)
+	query.Set("code", code)
+	query.Set("grant_type", "authorization_code")
+
+	resp, err := this.httpClient().Get(oauthAccessTokenUrl + "?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		OAuthError
+		OpenId string `json:"openid"`
+	}
+	if err := json.Unmarshal(respData, &result); err != nil {
+		return "", err
+	}
+	if result.ErrCode != 0 {
+		return "", &result.OAuthError
+	}
+	if result.OpenId == "" {
+		return "", fmt.Errorf("openid is missing in oauth response")
+	}
+
+	return result.OpenId, nil
+}
@@ -0,0 +1,62 @@
+package wxpay
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpenIdLength is the length of openid issued by weixin so far, it is not a documented contract
+const OpenIdLength = 28
+
+// ValidateOpenId check the openid is well-formed, it is 28 characters of letters, digits, '-' and '_'
+func ValidateOpenId(openId string) error {
+	if len(openId) != OpenIdLength {
+		return fmt.Errorf("openid should be %d characters, got:%s", OpenIdLength, openId)
+	}
+	return validateOpenIdCharset(openId)
+}
+
+// validateOpenIdCharset check the openid is not empty and has only letters, digits, '-' and '_'
+func validateOpenIdCharset(openId string) error {
+	if openId == "" {
+		return fmt.Errorf("openid is empty")
+	}
+	for _, c := range openId {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("invalid character %q in openid:%s", c, openId)
+		}
+	}
+
+	return nil
+}
+
+// checkOpenId validate the openid of JSAPI order, and check it belong to the configured appid
+// with WxConfig.OpenIdPrefix, so order with openid of other appid fails before submission.
+// The length is only checked if WxConfig.StrictOpenId is set.
+func (this *AppTrans) checkOpenId(order map[string]string) error {
+	if TradeType(order["trade_type"]) != TradeTypeJsApi {
+		return nil
+	}
+
+	openId := order["openid"]
+	if openId == "" && order["sub_openid"] != "" {
+		// openid of sub_appid in service provider mode, OpenIdPrefix is for AppId only
+		return this.validateOpenId(order["sub_openid"])
+	}
+	if err := this.validateOpenId(openId); err != nil {
+		return err
+	}
+	if prefix := this.Config.OpenIdPrefix; prefix != "" && !strings.HasPrefix(openId, prefix) {
+		return fmt.Errorf("openid %s is not issued for appid %s, want prefix:%s", openId, this.Config.AppId, prefix)
+	}
+
+	return nil
+}
+
+// validateOpenId check the format of openid, with ValidateOpenId if WxConfig.StrictOpenId is set
+func (this *AppTrans) validateOpenId(openId string) error {
+	if this.Config.StrictOpenId {
+		return ValidateOpenId(openId)
+	}
+	return validateOpenIdCharset(openId)
+}
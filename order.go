@@ -0,0 +1,181 @@
+package wxpay
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// LimitPay restrict the payment method of an order
+type LimitPay string
+
+const (
+	// LimitPayNoCredit forbid paying the order with credit card
+	LimitPayNoCredit LimitPay = "no_credit"
+)
+
+// OrderVersionSingleItem is the version required by single item promotion(单品优惠),
+// the goods detail must be sent in detail field with this version
+const OrderVersionSingleItem = "1.0"
+
+// MaxTotalFee is the upper bound of amount in fen accepted by the typed requests,
+// it is a sanity check against amounts wrongly in yuan*10000 etc., not the limit of merchant
+var MaxTotalFee int64 = 100000000 * 100
+
+const (
+	maxGoodsTagLength = 32
+	maxDetailLength   = 6000
+)
+
+// OrderRequest is the typed form of place order parameters.
+// Fields like appid, mch_id, nonce_str, notify_url and trade_type are filled from WxConfig when submit.
+// For field explanation refer to: http://pay.weixin.qq.com/wiki/doc/api/app.php?chapter=9_1
+type OrderRequest struct {
+	Body       string
+	Detail     string
+	Attach     string
+	OutTradeNo string
+	FeeType    string
+	// TotalFee is the amount in fen(分), must be positive and not greater than MaxTotalFee
+	TotalFee       int64
+	SpbillCreateIp string
+	TimeStart      string
+	TimeExpire     string
+	LimitPay       LimitPay
+	// OpenId is required when trade type is JSAPI
+	OpenId string
+	// ProductId is required when trade type is NATIVE
+	ProductId string
+	// SceneInfo is required when trade type is MWEB
+	SceneInfo string
+	// GoodsTag is the coupon or promotion tag(代金券/立减优惠) configured in merchant platform
+	GoodsTag string
+	// Version should be OrderVersionSingleItem for single item promotion, empty otherwise
+	Version string
+	// Receipt show the invoice entry on the payment success page
+	Receipt bool
+	// ProfitSharing mark the order for later profit sharing, it can only be set on order creation
+	ProfitSharing bool
+	// SignType override WxConfig.SignType for this order, the response and the payment
+	// notification of the order are verified with it too
+	SignType string
+	// Extra is merged into the signed parameters, for fields not covered by the struct yet.
+	// It cannot override the typed fields.
+	Extra map[string]string
+}
+
+// ToMap convert the order request to parameters accepted by Submit, empty fields are omitted
+func (this *OrderRequest) ToMap() map[string]string {
+	param := make(map[string]string)
+	setIfNotEmpty(param, "body", this.Body)
+	setIfNotEmpty(param, "detail", this.Detail)
+	setIfNotEmpty(param, "attach", this.Attach)
+	setIfNotEmpty(param, "out_trade_no", this.OutTradeNo)
+	setIfNotEmpty(param, "fee_type", this.FeeType)
+	param["total_fee"] = strconv.FormatInt(this.TotalFee, 10)
+	setIfNotEmpty(param, "spbill_create_ip", this.SpbillCreateIp)
+	setIfNotEmpty(param, "time_start", this.TimeStart)
+	setIfNotEmpty(param, "time_expire", this.TimeExpire)
+	setIfNotEmpty(param, "limit_pay", string(this.LimitPay))
+	setIfNotEmpty(param, "openid", this.OpenId)
+	setIfNotEmpty(param, "product_id", this.ProductId)
+	setIfNotEmpty(param, "scene_info", this.SceneInfo)
+	setIfNotEmpty(param, "goods_tag", this.GoodsTag)
+	setIfNotEmpty(param, "version", this.Version)
+	setIfNotEmpty(param, "sign_type", this.SignType)
+	if this.Receipt {
+		param["receipt"] = "Y"
+	}
+	if this.ProfitSharing {
+		param["profit_sharing"] = "Y"
+	}
+	mergeExtra(param, this.Extra)
+
+	return param
+}
+
+// Validate check the amount and promotion related fields of the order request
+func (this *OrderRequest) Validate() error {
+	if err := validateFee("total_fee", this.TotalFee); err != nil {
+		return err
+	}
+	if len(this.GoodsTag) > maxGoodsTagLength {
+		return fmt.Errorf("goods_tag exceed %d characters:%s", maxGoodsTagLength, this.GoodsTag)
+	}
+	if this.Version != "" && this.Version != OrderVersionSingleItem {
+		return fmt.Errorf("unsupported version:%s, only %s is allowed", this.Version, OrderVersionSingleItem)
+	}
+	if this.Version == OrderVersionSingleItem && this.Detail == "" {
+		return fmt.Errorf("detail is required for version %s", OrderVersionSingleItem)
+	}
+	if len(this.Detail) > maxDetailLength {
+		return fmt.Errorf("detail exceed %d characters", maxDetailLength)
+	}
+
+	return nil
+}
+
+// SubmitOrder validate and submit the typed order request, see Submit for more information
+func (this *AppTrans) SubmitOrder(req *OrderRequest) (*PlaceOrderResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	return this.Submit(req.ToMap())
+}
+
+// validateOrderParams check the order has the parameters required by its trade type before submission
+func validateOrderParams(order map[string]string) error {
+	tradeType := TradeType(order["trade_type"])
+	if !tradeType.Valid() {
+		return fmt.Errorf("unknown trade type:%s", tradeType)
+	}
+	if tradeType == TradeTypeMicropay {
+		return fmt.Errorf("trade type %s cannot be placed with unified order", tradeType)
+	}
+
+	for _, key := range tradeType.requiredParams() {
+		// in service provider mode, JSAPI order may carry sub_openid of sub_appid instead
+		if key == "openid" && order["sub_openid"] != "" {
+			continue
+		}
+		if order[key] == "" {
+			return fmt.Errorf("%s is required when trade type is %s", key, tradeType)
+		}
+	}
+
+	return nil
+}
+
+// validateFee check the amount in fen is positive and within MaxTotalFee
+func validateFee(name string, fee int64) error {
+	if fee <= 0 {
+		return fmt.Errorf("%s must be positive, got:%d", name, fee)
+	}
+	if fee > MaxTotalFee {
+		return fmt.Errorf("%s exceed %d fen, got:%d", name, MaxTotalFee, fee)
+	}
+	return nil
+}
+
+// formatFee validate the amount in fen and format it for the request
+func formatFee(name string, fee int64) (string, error) {
+	if err := validateFee(name, fee); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(fee, 10), nil
+}
+
+// mergeExtra add extra fields to param, existing keys are kept
+func mergeExtra(param map[string]string, extra map[string]string) {
+	for k, v := range extra {
+		if _, ok := param[k]; !ok {
+			param[k] = v
+		}
+	}
+}
+
+func setIfNotEmpty(param map[string]string, key, value string) {
+	if value != "" {
+		param[key] = value
+	}
+}
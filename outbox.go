@@ -0,0 +1,377 @@
+package wxpay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OutboxEvent is the "payment received" event to be published downstream, Id is the transaction_id
+// so consumers can deduplicate events published more than once
+type OutboxEvent struct {
+	Id           string
+	Time         time.Time
+	Notification PaymentNotification
+}
+
+// OutboxStore persist the credited payments and their events. Implement it with the database of orders,
+// so crediting the order and saving the event are in one transaction.
+type OutboxStore interface {
+	// Credit credit the order of the paid notification and save the event atomically. It return false
+	// without error if the payment is already credited, e.g. for notification sent again.
+	Credit(notification PaymentNotification, event OutboxEvent) (bool, error)
+	// Pending return at most limit events not published yet, oldest first
+	Pending(limit int) ([]OutboxEvent, error)
+	// MarkPublished mark the event published, it is not returned by Pending any more
+	MarkPublished(id string) error
+}
+
+// OutboxHandle return the handle for NotifyHandler crediting paid orders exactly once with store.
+// Notifications of failed payment are acknowledged without crediting. If store fails, FAIL is replied
+// and weixin pay send the notification again, the order is never credited twice.
+func (this *AppTrans) OutboxHandle(store OutboxStore) func(PaymentNotification) error {
+	return func(notification PaymentNotification) error {
+		if notification.ResultCode != "SUCCESS" {
+			return nil
+		}
+
+		event := OutboxEvent{Id: notification.TransactionId, Time: time.Now(), Notification: notification}
+		_, err := store.Credit(notification, event)
+		return err
+	}
+}
+
+// DefaultOutboxBatchSize is the default of OutboxDispatcher.BatchSize
+const DefaultOutboxBatchSize = 100
+
+// DefaultOutboxPoll is the default of OutboxDispatcher.Poll
+var DefaultOutboxPoll = Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+// DefaultOutboxRetry is the default of OutboxDispatcher.Retry, it gives up an event after about an hour
+var DefaultOutboxRetry = Backoff{Initial: time.Second, Max: 10 * time.Minute, Multiplier: 2, Jitter: 0.2, MaxAttempts: 15}
+
+// DefaultOutboxLockName is the default of OutboxDispatcher.LockName
+const DefaultOutboxLockName = "wxpay:outbox"
+
+// DefaultOutboxLockTTL is the default of OutboxDispatcher.LockTTL
+const DefaultOutboxLockTTL = time.Minute
+
+// OutboxDispatcher publish the pending events of Store downstream, events are published at least once
+type OutboxDispatcher struct {
+	Store   OutboxStore
+	Publish func(OutboxEvent) error
+	// BatchSize is the max events fetched at once, DefaultOutboxBatchSize if not positive
+	BatchSize int
+	// Poll is the delay before fetching again when no event is pending or publish fails,
+	// DefaultOutboxPoll if Initial is not set
+	Poll Backoff
+	// Retry is the delay before publishing an event again after it failed, e.g. while the database of the
+	// consumer is down, DefaultOutboxRetry if Initial is not set. The event is passed to DeadLetter after
+	// Retry.MaxAttempts attempts failed, zero MaxAttempts means no limit.
+	Retry Backoff
+	// DeadLetter receive the event given up with the error of its last attempt, e.g. to save it for manual
+	// handling. The event is marked published once DeadLetter return nil, otherwise it is retried later.
+	// If DeadLetter is nil, the event is retried without limit.
+	DeadLetter func(OutboxEvent, error) error
+
+	// Locker let only one of the dispatchers running in replicas publish the events in Run, the others
+	// wait by Poll and take over once the lock expire. Without it every replica publish the pending
+	// events, and an event may be published by more than one of them, consumers deduplicate it by Id.
+	Locker Locker
+	// LockName is the name of lock, DefaultOutboxLockName if empty. Dispatchers of different stores
+	// should use different names.
+	LockName string
+	// LockOwner identify this dispatcher to Locker, a random one is generated if empty
+	LockOwner string
+	// LockTTL is how long the lock is held after each batch, DefaultOutboxLockTTL if not positive.
+	// It should be longer than publishing a batch, or another dispatcher may take over meanwhile.
+	LockTTL time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*outboxFailure
+	shutdown bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// outboxFailure is the failed attempts of publishing an event
+type outboxFailure struct {
+	attempts int
+	next     time.Time
+}
+
+// DispatchOnce publish a batch of pending events in order and return the number published. An event
+// failed to publish does not block the others, so events after it may be published before it, and it
+// is skipped until the delay of Retry passed. The error of the last failure is returned when any event
+// of the batch failed.
+func (this *OutboxDispatcher) DispatchOnce() (int, error) {
+	batchSize := this.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultOutboxBatchSize
+	}
+
+	events, err := this.Store.Pending(batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var lastErr error
+	for _, event := range events {
+		if !this.due(event.Id) {
+			continue
+		}
+
+		err := this.Publish(event)
+		if err != nil {
+			if err = this.failed(event, err); err != nil {
+				lastErr = err
+				continue
+			}
+		} else {
+			published++
+		}
+
+		if err := this.Store.MarkPublished(event.Id); err != nil {
+			return published, err
+		}
+		this.forget(event.Id)
+	}
+	return published, lastErr
+}
+
+// dispatchLocked is DispatchOnce if the lock of Locker is acquired, it publish nothing otherwise
+func (this *OutboxDispatcher) dispatchLocked() (int, error) {
+	if this.Locker == nil {
+		return this.DispatchOnce()
+	}
+
+	ttl := this.LockTTL
+	if ttl <= 0 {
+		ttl = DefaultOutboxLockTTL
+	}
+	locked, err := this.Locker.TryLock(this.lockName(), this.lockOwner(), ttl)
+	if err != nil || !locked {
+		return 0, err
+	}
+	return this.DispatchOnce()
+}
+
+// unlock release the lock of Locker held by this dispatcher
+func (this *OutboxDispatcher) unlock() {
+	this.Locker.Unlock(this.lockName(), this.lockOwner())
+}
+
+func (this *OutboxDispatcher) lockName() string {
+	if this.LockName == "" {
+		return DefaultOutboxLockName
+	}
+	return this.LockName
+}
+
+func (this *OutboxDispatcher) lockOwner() string {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.LockOwner == "" {
+		this.LockOwner = NewNonceString()
+	}
+	return this.LockOwner
+}
+
+// due tell whether the event is published for the first time or its retry delay passed
+func (this *OutboxDispatcher) due(id string) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	failure, ok := this.failures[id]
+	return !ok || !time.Now().Before(failure.next)
+}
+
+// failed count the failed attempt of event, the event is passed to DeadLetter once the attempts run out.
+// It return nil if the event is dead-lettered, otherwise the error to retry it later.
+func (this *OutboxDispatcher) failed(event OutboxEvent, err error) error {
+	retry := this.Retry
+	if retry.Initial <= 0 {
+		retry = DefaultOutboxRetry
+	}
+
+	this.mu.Lock()
+	if this.failures == nil {
+		this.failures = make(map[string]*outboxFailure)
+	}
+	failure, ok := this.failures[event.Id]
+	if !ok {
+		failure = &outboxFailure{}
+		this.failures[event.Id] = failure
+	}
+	failure.attempts++
+	failure.next = time.Now().Add(retry.Delay(failure.attempts))
+	attempts := failure.attempts
+	this.mu.Unlock()
+
+	if retry.Allow(attempts+1) || this.DeadLetter == nil {
+		return err
+	}
+	if dlErr := this.DeadLetter(event, err); dlErr != nil {
+		return fmt.Errorf("event %s failed %d attempts and dead letter failed: %v", event.Id, attempts, dlErr)
+	}
+	return nil
+}
+
+// forget drop the failures of the event published or dead-lettered
+func (this *OutboxDispatcher) forget(id string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	delete(this.failures, id)
+}
+
+// ErrDispatcherShutdown is returned by OutboxDispatcher.Run after Shutdown is called
+var ErrDispatcherShutdown = errors.New("outbox dispatcher is shut down")
+
+// Run dispatch the events until ctx is done or Shutdown is called, it return the error of ctx, or nil
+// after the pending events are drained for Shutdown. It waits by Poll after a batch with nothing
+// published, failed events are retried by Retry in the batches after. With Locker, batches are only
+// dispatched while the lock is held, and it is released on return. Run is the only goroutine of the
+// dispatcher, at most one Run is allowed at a time.
+func (this *OutboxDispatcher) Run(ctx context.Context) error {
+	this.mu.Lock()
+	if this.shutdown {
+		this.mu.Unlock()
+		return ErrDispatcherShutdown
+	}
+	if this.done != nil {
+		this.mu.Unlock()
+		return errors.New("outbox dispatcher is already running")
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	this.stop, this.done = stop, done
+	this.mu.Unlock()
+
+	defer func() {
+		if this.Locker != nil {
+			this.unlock()
+		}
+		this.mu.Lock()
+		this.stop, this.done = nil, nil
+		this.mu.Unlock()
+		close(done)
+	}()
+
+	poll := this.Poll
+	if poll.Initial <= 0 {
+		poll = DefaultOutboxPoll
+	}
+
+	idle := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stop:
+			this.drain()
+			return nil
+		default:
+		}
+
+		published, err := this.dispatchLocked()
+		if err == nil && published > 0 {
+			idle = 0
+			continue
+		}
+		idle++
+
+		timer := time.NewTimer(poll.Delay(idle))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		case <-stop:
+		}
+		timer.Stop()
+	}
+}
+
+// drain dispatch the batches until nothing more is published, events waiting for retry are left pending
+func (this *OutboxDispatcher) drain() {
+	for {
+		if published, _ := this.dispatchLocked(); published == 0 {
+			return
+		}
+	}
+}
+
+// Shutdown stop Run and wait until the pending events are drained and Run return, or ctx is done.
+// The batch being published is finished first, Publish is never interrupted. Run is not allowed after it.
+func (this *OutboxDispatcher) Shutdown(ctx context.Context) error {
+	this.mu.Lock()
+	if !this.shutdown {
+		this.shutdown = true
+		if this.stop != nil {
+			close(this.stop)
+		}
+	}
+	done := this.done
+	this.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MemoryOutbox is an OutboxStore in process memory, for tests and single process tools,
+// it only records the credited transactions and leaves crediting orders to the caller
+type MemoryOutbox struct {
+	mu       sync.Mutex
+	credited map[string]bool
+	pending  []OutboxEvent
+}
+
+// NewMemoryOutbox return an empty MemoryOutbox
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{credited: make(map[string]bool)}
+}
+
+func (this *MemoryOutbox) Credit(notification PaymentNotification, event OutboxEvent) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.credited[event.Id] {
+		return false, nil
+	}
+	this.credited[event.Id] = true
+	this.pending = append(this.pending, event)
+	return true, nil
+}
+
+func (this *MemoryOutbox) Pending(limit int) ([]OutboxEvent, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if len(this.pending) < limit {
+		limit = len(this.pending)
+	}
+	return append([]OutboxEvent(nil), this.pending[:limit]...), nil
+}
+
+// MarkPublished remove the event from pending ones
+func (this *MemoryOutbox) MarkPublished(id string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	for i, event := range this.pending {
+		if event.Id == id {
+			this.pending = append(this.pending[:i], this.pending[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package wxpay
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+)
+
+// PayBankRequest is the parameter of transfer to bank card(企业付款到银行卡), card number and name
+// are in plain text, they are encrypted with the public key from GetPublicKey before sending.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/tools/mch_pay.php?chapter=24_2
+type PayBankRequest struct {
+	PartnerTradeNo string
+	BankNo         string
+	TrueName       string
+	// BankCode is the code of bank defined by weixin pay, e.g. 1002 for ICBC
+	BankCode string
+	// Amount is in fen
+	Amount int64
+	Desc   string
+	// Extra is merged into the signed parameters, it cannot override the typed fields
+	Extra map[string]string
+}
+
+// PayBankResult represent the response of transfer to bank card
+type PayBankResult struct {
+	XMLName        xml.Name `xml:"xml"`
+	ReturnCode     string   `xml:"return_code"`
+	ReturnMsg      string   `xml:"return_msg"`
+	ResultCode     string   `xml:"result_code"`
+	ErrCode        string   `xml:"err_code"`
+	ErrCodeDesc    string   `xml:"err_code_des"`
+	MchId          string   `xml:"mch_id"`
+	PartnerTradeNo string   `xml:"partner_trade_no"`
+	Amount         string   `xml:"amount"`
+	NonceStr       string   `xml:"nonce_str"`
+	PaymentNo      string   `xml:"payment_no"`
+	// CmmsAmt is the fee charged in fen
+	CmmsAmt string `xml:"cmms_amt"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// GetPublicKey get the RSA public key of merchant in PEM format for encrypting the bank card information.
+// Merchant certificate is required.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/tools/mch_pay.php?chapter=24_7
+func (this *AppTrans) GetPublicKey() (string, error) {
+	param := make(map[string]string)
+	param["mch_id"] = this.Config.MchId
+	// sign_type is required by this api even for MD5
+	param["sign_type"] = SignTypeMD5
+
+	_, respInMap, envelope, err := this.call(context.Background(), Endpoints[PathGetPublicKey], param)
+	if err != nil {
+		return "", err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return "", err
+	}
+	if respInMap["pub_key"] == "" {
+		return "", fmt.Errorf("pub_key is missing in response")
+	}
+
+	return respInMap["pub_key"], nil
+}
+
+// PayBank transfer to the bank card, the public key is fetched on first use and cached.
+// Merchant certificate is required. On SYSTEMERROR retry with the same PartnerTradeNo.
+func (this *AppTrans) PayBank(req *PayBankRequest) (*PayBankResult, error) {
+	amount, err := formatFee("amount", req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := this.bankPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	encBankNo, err := EncryptOAEP(publicKey, req.BankNo)
+	if err != nil {
+		return nil, err
+	}
+	encTrueName, err := EncryptOAEP(publicKey, req.TrueName)
+	if err != nil {
+		return nil, err
+	}
+
+	param := make(map[string]string)
+	param["mch_id"] = this.Config.MchId
+	param["partner_trade_no"] = req.PartnerTradeNo
+	param["enc_bank_no"] = encBankNo
+	param["enc_true_name"] = encTrueName
+	param["bank_code"] = req.BankCode
+	param["amount"] = amount
+	setIfNotEmpty(param, "desc", req.Desc)
+	mergeExtra(param, req.Extra)
+
+	resp, respInMap, envelope, err := this.call(context.Background(), Endpoints[PathPayBank], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	result := PayBankResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// bankPublicKey return the cached public key for bank transfer, fetching it with GetPublicKey if needed
+func (this *AppTrans) bankPublicKey() (*rsa.PublicKey, error) {
+	this.bankKeyMu.Lock()
+	defer this.bankKeyMu.Unlock()
+
+	if this.bankKey != nil {
+		return this.bankKey, nil
+	}
+
+	pemKey, err := this.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := ParseRsaPublicKey(pemKey)
+	if err != nil {
+		return nil, err
+	}
+
+	this.bankKey = publicKey
+	return publicKey, nil
+}
+
+// ParseRsaPublicKey parse the RSA public key in PEM format, both PKCS#1(BEGIN RSA PUBLIC KEY,
+// as returned by GetPublicKey) and PKIX(BEGIN PUBLIC KEY) are accepted
+func ParseRsaPublicKey(pemKey string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key in PEM format")
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not RSA")
+	}
+	return publicKey, nil
+}
+
+// EncryptOAEP encrypt the text with RSA OAEP(SHA-1) and encode the result in base64,
+// as required by enc_bank_no and enc_true_name
+func EncryptOAEP(publicKey *rsa.PublicKey, text string) (string, error) {
+	cipherText, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, publicKey, []byte(text), nil)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(cipherText), nil
+}
@@ -0,0 +1,58 @@
+package wxpay
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+func TestEncryptOAEP(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, keyPem := range []string{
+		string(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: mustMarshalPKIX(t, &privateKey.PublicKey)})),
+	} {
+		publicKey, err := ParseRsaPublicKey(keyPem)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encrypted, err := EncryptOAEP(publicKey, "张三")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cipherText, err := base64.StdEncoding.DecodeString(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := rsa.DecryptOAEP(sha1.New(), nil, privateKey, cipherText, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(plain) != "张三" {
+			t.Errorf("got %q", plain)
+		}
+	}
+}
+
+func TestParseRsaPublicKeyInvalid(t *testing.T) {
+	if _, err := ParseRsaPublicKey("not a key"); err == nil {
+		t.Error("want error")
+	}
+}
+
+func mustMarshalPKIX(t *testing.T, publicKey *rsa.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
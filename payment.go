@@ -9,3 +9,46 @@ type PaymentRequest struct {
 	Timestamp string
 	Sign      string
 }
+
+// JsApiPaymentRequest is the parameter of WeixinJSBridge.invoke('getBrandWCPayRequest', ...) for JSAPI payment,
+// it can be marshaled to json and passed to the page as is.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/jsapi.php?chapter=7_7
+type JsApiPaymentRequest struct {
+	AppId     string `json:"appId"`
+	Timestamp string `json:"timeStamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
+
+// ChooseWXPayRequest is the parameter of wx.chooseWXPay of JS-SDK, note the lower case timestamp
+// and no appId, which is given in wx.config
+type ChooseWXPayRequest struct {
+	Timestamp string `json:"timestamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
+
+// ForChooseWXPay convert the request to the parameter of wx.chooseWXPay, the paySign is the same
+func (this JsApiPaymentRequest) ForChooseWXPay() ChooseWXPayRequest {
+	return ChooseWXPayRequest{
+		Timestamp: this.Timestamp,
+		NonceStr:  this.NonceStr,
+		Package:   this.Package,
+		SignType:  this.SignType,
+		PaySign:   this.PaySign,
+	}
+}
+
+// MiniProgramPaymentRequest is the parameter of wx.requestPayment in mini program.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/wxa/wxa_api.php?chapter=7_7&index=5
+type MiniProgramPaymentRequest struct {
+	Timestamp string `json:"timeStamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
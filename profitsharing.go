@@ -0,0 +1,165 @@
+package wxpay
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+)
+
+const (
+	// ReceiverTypeMerchant share to merchant by mch_id
+	ReceiverTypeMerchant = "MERCHANT_ID"
+	// ReceiverTypeWechatId share to user by weixin id
+	ReceiverTypeWechatId = "PERSONAL_WECHATID"
+	// ReceiverTypeOpenId share to user by openid of appid
+	ReceiverTypeOpenId = "PERSONAL_OPENID"
+)
+
+// ProfitSharingReceiver is a receiver of profit sharing, Amount is only used for sharing requests,
+// Name and RelationType only for adding receiver, CustomRelation is required when RelationType is CUSTOM
+type ProfitSharingReceiver struct {
+	Type           string `json:"type"`
+	Account        string `json:"account"`
+	Amount         int64  `json:"amount,omitempty"`
+	Description    string `json:"description,omitempty"`
+	Name           string `json:"name,omitempty"`
+	RelationType   string `json:"relation_type,omitempty"`
+	CustomRelation string `json:"custom_relation,omitempty"`
+	// Result and FinishTime etc. are only in responses of query
+	Result     string `json:"result,omitempty"`
+	FinishTime string `json:"finish_time,omitempty"`
+	FailReason string `json:"fail_reason,omitempty"`
+}
+
+// ProfitSharingResult represent the response of profit sharing requests, Receivers is in json
+type ProfitSharingResult struct {
+	XMLName       xml.Name `xml:"xml"`
+	ReturnCode    string   `xml:"return_code"`
+	ReturnMsg     string   `xml:"return_msg"`
+	ResultCode    string   `xml:"result_code"`
+	ErrCode       string   `xml:"err_code"`
+	ErrCodeDesc   string   `xml:"err_code_des"`
+	MchId         string   `xml:"mch_id"`
+	AppId         string   `xml:"appid"`
+	NonceStr      string   `xml:"nonce_str"`
+	Sign          string   `xml:"sign"`
+	TransactionId string   `xml:"transaction_id"`
+	OutOrderNo    string   `xml:"out_order_no"`
+	OrderId       string   `xml:"order_id"`
+	// Status is ACCEPTED, PROCESSING, FINISHED or CLOSED, only in response of query
+	Status      string `xml:"status"`
+	CloseReason string `xml:"close_reason"`
+	Receivers   string `xml:"receivers"`
+	Amount      string `xml:"amount"`
+	Description string `xml:"description"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// ParsedReceivers return the receivers in response of query
+func (this *ProfitSharingResult) ParsedReceivers() ([]ProfitSharingReceiver, error) {
+	var receivers []ProfitSharingReceiver
+	if this.Receivers == "" {
+		return receivers, nil
+	}
+	err := json.Unmarshal([]byte(this.Receivers), &receivers)
+	return receivers, err
+}
+
+// ProfitSharing share the profit of the order marked with OrderRequest.ProfitSharing once, the rest
+// is unfrozen to merchant immediately. Merchant certificate is required.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/allocation.php?chapter=27_1&index=1
+func (this *AppTrans) ProfitSharing(transactionId, outOrderNo string, receivers []ProfitSharingReceiver) (*ProfitSharingResult, error) {
+	return this.shareProfit(Endpoints[PathProfitSharing], transactionId, outOrderNo, receivers)
+}
+
+// MultiProfitSharing share the profit of the order, it can be called more than once until
+// FinishProfitSharing. Merchant certificate is required.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/allocation.php?chapter=27_6&index=2
+func (this *AppTrans) MultiProfitSharing(transactionId, outOrderNo string, receivers []ProfitSharingReceiver) (*ProfitSharingResult, error) {
+	return this.shareProfit(Endpoints[PathMultiProfitSharing], transactionId, outOrderNo, receivers)
+}
+
+func (this *AppTrans) shareProfit(ep Endpoint, transactionId, outOrderNo string, receivers []ProfitSharingReceiver) (*ProfitSharingResult, error) {
+	for _, receiver := range receivers {
+		if err := validateFee("amount of receiver "+receiver.Account, receiver.Amount); err != nil {
+			return nil, err
+		}
+	}
+	receiversInJson, err := json.Marshal(receivers)
+	if err != nil {
+		return nil, err
+	}
+
+	param := this.newParam()
+	param["transaction_id"] = transactionId
+	param["out_order_no"] = outOrderNo
+	param["receivers"] = string(receiversInJson)
+
+	return this.callProfitSharing(ep, param)
+}
+
+// QueryProfitSharing query the profit sharing request by transaction_id and out_order_no
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/allocation.php?chapter=27_2&index=3
+func (this *AppTrans) QueryProfitSharing(transactionId, outOrderNo string) (*ProfitSharingResult, error) {
+	// the api does not accept appid
+	param := map[string]string{"mch_id": this.Config.MchId}
+	param["transaction_id"] = transactionId
+	param["out_order_no"] = outOrderNo
+
+	return this.callProfitSharing(Endpoints[PathProfitSharingQuery], param)
+}
+
+// AddProfitSharingReceiver add the receiver before sharing profit to it, Name is required
+// for ReceiverTypeMerchant and ReceiverTypeWechatId
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/allocation.php?chapter=27_3&index=4
+func (this *AppTrans) AddProfitSharingReceiver(receiver ProfitSharingReceiver) (*ProfitSharingResult, error) {
+	return this.changeProfitSharingReceiver(Endpoints[PathProfitSharingAddReceiver], receiver)
+}
+
+// RemoveProfitSharingReceiver remove the receiver, only Type and Account are used
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/allocation.php?chapter=27_4&index=5
+func (this *AppTrans) RemoveProfitSharingReceiver(receiver ProfitSharingReceiver) (*ProfitSharingResult, error) {
+	return this.changeProfitSharingReceiver(Endpoints[PathProfitSharingRemoveReceiver], ProfitSharingReceiver{Type: receiver.Type, Account: receiver.Account})
+}
+
+func (this *AppTrans) changeProfitSharingReceiver(ep Endpoint, receiver ProfitSharingReceiver) (*ProfitSharingResult, error) {
+	receiverInJson, err := json.Marshal(receiver)
+	if err != nil {
+		return nil, err
+	}
+
+	param := this.newParam()
+	param["receiver"] = string(receiverInJson)
+
+	return this.callProfitSharing(ep, param)
+}
+
+// FinishProfitSharing unfreeze the remaining amount of the order to merchant after MultiProfitSharing.
+// Merchant certificate is required.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/allocation.php?chapter=27_5&index=6
+func (this *AppTrans) FinishProfitSharing(transactionId, outOrderNo, description string) (*ProfitSharingResult, error) {
+	param := this.newParam()
+	param["transaction_id"] = transactionId
+	param["out_order_no"] = outOrderNo
+	param["amount"] = "0"
+	param["description"] = description
+
+	return this.callProfitSharing(Endpoints[PathProfitSharingFinish], param)
+}
+
+func (this *AppTrans) callProfitSharing(ep Endpoint, param map[string]string) (*ProfitSharingResult, error) {
+	resp, respInMap, envelope, err := this.call(context.Background(), ep, param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	result := ProfitSharingResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,97 @@
+package wxpay
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PromotionDetail is one promotion applied to the order, e.g. single item promotion(单品优惠).
+// Amounts are in fen.
+type PromotionDetail struct {
+	PromotionId        string        `json:"promotion_id"`
+	Name               string        `json:"name,omitempty"`
+	Scope              string        `json:"scope,omitempty"`
+	Type               string        `json:"type,omitempty"`
+	Amount             int64         `json:"amount"`
+	ActivityId         string        `json:"activity_id,omitempty"`
+	WxpayContribute    int64         `json:"wxpay_contribute"`
+	MerchantContribute int64         `json:"merchant_contribute"`
+	OtherContribute    int64         `json:"other_contribute"`
+	GoodsDetail        []GoodsDetail `json:"goods_detail,omitempty"`
+}
+
+// GoodsDetail is a goods of order, used both in detail of order request and promotion results
+type GoodsDetail struct {
+	GoodsId        string `json:"goods_id"`
+	WxpayGoodsId   string `json:"wxpay_goods_id,omitempty"`
+	GoodsName      string `json:"goods_name,omitempty"`
+	GoodsRemark    string `json:"goods_remark,omitempty"`
+	Quantity       int64  `json:"quantity"`
+	Price          int64  `json:"price"`
+	DiscountAmount int64  `json:"discount_amount,omitempty"`
+}
+
+// OrderDetail is the detail field of single item promotion(单品优惠) order, amounts are in fen
+type OrderDetail struct {
+	// CostPrice is the original price of the whole receipt, promotion only apply when it equals total_fee
+	CostPrice   int64         `json:"cost_price,omitempty"`
+	ReceiptId   string        `json:"receipt_id,omitempty"`
+	GoodsDetail []GoodsDetail `json:"goods_detail"`
+}
+
+// Validate check the detail is consistent with the total fee of order
+func (this *OrderDetail) Validate(totalFee int64) error {
+	if this.CostPrice < 0 {
+		return fmt.Errorf("invalid cost_price:%d", this.CostPrice)
+	}
+	if this.CostPrice > 0 && this.CostPrice != totalFee {
+		return fmt.Errorf("cost_price %d not equal to total_fee %d, promotion will not apply", this.CostPrice, totalFee)
+	}
+
+	var goodsTotal int64
+	for _, goods := range this.GoodsDetail {
+		if goods.GoodsId == "" {
+			return fmt.Errorf("goods_id is required in goods_detail")
+		}
+		if goods.Quantity <= 0 || goods.Price < 0 {
+			return fmt.Errorf("invalid quantity or price of goods %s", goods.GoodsId)
+		}
+		goodsTotal += goods.Price * goods.Quantity
+	}
+	if goodsTotal > totalFee {
+		return fmt.Errorf("total of goods_detail %d exceed total_fee %d", goodsTotal, totalFee)
+	}
+
+	return nil
+}
+
+// SetDetail validate the detail against TotalFee, and set it to Detail in json
+func (this *OrderRequest) SetDetail(detail *OrderDetail) error {
+	if err := detail.Validate(this.TotalFee); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	this.Detail = string(data)
+	return nil
+}
+
+// ParsePromotionDetail parse promotion_detail field of query result or notification,
+// which is a json like {"promotion_detail":[...]}. Empty string result in no promotions.
+func ParsePromotionDetail(promotionDetail string) ([]PromotionDetail, error) {
+	if promotionDetail == "" {
+		return nil, nil
+	}
+
+	var container struct {
+		PromotionDetail []PromotionDetail `json:"promotion_detail"`
+	}
+	if err := json.Unmarshal([]byte(promotionDetail), &container); err != nil {
+		return nil, err
+	}
+
+	return container.PromotionDetail, nil
+}
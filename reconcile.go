@@ -0,0 +1,152 @@
+package wxpay
+
+import (
+	"io"
+	"sort"
+)
+
+// LocalOrder is the order recorded by merchant, to be reconciled with the bill
+type LocalOrder struct {
+	OutTradeNo string
+	TotalFee   Fen
+	// TradeState is SUCCESS for paid orders, or REFUND if any refund is made
+	TradeState string
+}
+
+// LocalOrderIterator iterate the local orders of the bill date, Next return io.EOF after the last one
+type LocalOrderIterator interface {
+	Next() (*LocalOrder, error)
+}
+
+// BillRecordReader read the bill records one at a time, *BillReader satisfy it
+type BillRecordReader interface {
+	Read() (*BillRecord, error)
+}
+
+// ReconcileDiffType is the kind of difference found in reconciliation
+type ReconcileDiffType string
+
+const (
+	// ReconcileMissingInBill means the local order is not in the bill
+	ReconcileMissingInBill ReconcileDiffType = "MISSING_IN_BILL"
+	// ReconcileMissingLocally means the order in bill is not recorded locally
+	ReconcileMissingLocally ReconcileDiffType = "MISSING_LOCALLY"
+	// ReconcileAmountMismatch means the order amount differ
+	ReconcileAmountMismatch ReconcileDiffType = "AMOUNT_MISMATCH"
+	// ReconcileStateMismatch means the order is refunded on one side only
+	ReconcileStateMismatch ReconcileDiffType = "STATE_MISMATCH"
+)
+
+// ReconcileDiff is a difference of an order, Local or Bill is nil if the order is missing on that side
+type ReconcileDiff struct {
+	Type       ReconcileDiffType
+	OutTradeNo string
+	Local      *LocalOrder
+	Bill       *BillRecord
+	// BillTotalFee and BillTradeState is the order amount and state derived from the bill
+	BillTotalFee   Fen
+	BillTradeState string
+}
+
+// ReconcileReport is the result of reconciliation, diffs are sorted by out_trade_no
+type ReconcileReport struct {
+	Matched int
+	Diffs   []ReconcileDiff
+}
+
+// billOrder is the order summarized from the payment row and refund rows of bill
+type billOrder struct {
+	record     *BillRecord
+	totalFee   Fen
+	tradeState string
+}
+
+// Reconcile compare the local orders with the bill(of type ALL) of the same date. The bill is indexed
+// in memory by out_trade_no, local orders are streamed. An order with refund rows in the bill is
+// considered in state REFUND.
+func Reconcile(local LocalOrderIterator, bill BillRecordReader) (*ReconcileReport, error) {
+	orders := make(map[string]*billOrder)
+	for {
+		record, err := bill.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		order := orders[record.OutTradeNo]
+		if order == nil {
+			order = &billOrder{}
+			orders[record.OutTradeNo] = order
+		}
+		if record.IsRefund() {
+			order.tradeState = "REFUND"
+			continue
+		}
+		order.record = record
+		order.totalFee = record.TotalFee
+		if order.totalFee == 0 {
+			order.totalFee = record.SettlementTotalFee + record.CouponFee
+		}
+		if order.tradeState == "" {
+			order.tradeState = record.TradeState
+		}
+	}
+
+	report := &ReconcileReport{}
+	seen := make(map[string]bool)
+	for {
+		localOrder, err := local.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		seen[localOrder.OutTradeNo] = true
+
+		order := orders[localOrder.OutTradeNo]
+		if order == nil || order.record == nil {
+			report.Diffs = append(report.Diffs, ReconcileDiff{Type: ReconcileMissingInBill, OutTradeNo: localOrder.OutTradeNo, Local: localOrder})
+			continue
+		}
+
+		diff := ReconcileDiff{
+			OutTradeNo:     localOrder.OutTradeNo,
+			Local:          localOrder,
+			Bill:           order.record,
+			BillTotalFee:   order.totalFee,
+			BillTradeState: order.tradeState,
+		}
+		switch {
+		case localOrder.TotalFee != order.totalFee:
+			diff.Type = ReconcileAmountMismatch
+		case localOrder.TradeState != order.tradeState:
+			diff.Type = ReconcileStateMismatch
+		default:
+			report.Matched++
+			continue
+		}
+		report.Diffs = append(report.Diffs, diff)
+	}
+
+	for outTradeNo, order := range orders {
+		// refund of an order paid on another day has no payment row, it is not missing locally
+		if seen[outTradeNo] || order.record == nil {
+			continue
+		}
+		report.Diffs = append(report.Diffs, ReconcileDiff{
+			Type:           ReconcileMissingLocally,
+			OutTradeNo:     outTradeNo,
+			Bill:           order.record,
+			BillTotalFee:   order.totalFee,
+			BillTradeState: order.tradeState,
+		})
+	}
+
+	sort.SliceStable(report.Diffs, func(i, j int) bool {
+		return report.Diffs[i].OutTradeNo < report.Diffs[j].OutTradeNo
+	})
+	return report, nil
+}
@@ -0,0 +1,33 @@
+package wxpay
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+type sliceOrders []LocalOrder
+
+func (this *sliceOrders) Next() (*LocalOrder, error) {
+	if len(*this) == 0 {
+		return nil, io.EOF
+	}
+	order := (*this)[0]
+	*this = (*this)[1:]
+	return &order, nil
+}
+
+func TestReconcile(t *testing.T) {
+	local := &sliceOrders{
+		{OutTradeNo: "T1", TotalFee: 100, TradeState: "REFUND"},
+		{OutTradeNo: "T2", TotalFee: 100, TradeState: "SUCCESS"},
+	}
+	report, err := Reconcile(local, NewBillReader(context.Background(), strings.NewReader(testBill)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Matched != 1 || len(report.Diffs) != 1 || report.Diffs[0].Type != ReconcileMissingInBill || report.Diffs[0].OutTradeNo != "T2" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
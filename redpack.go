@@ -0,0 +1,175 @@
+package wxpay
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// RedPackRequest is the parameter of sending cash red packet(现金红包).
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/tools/cash_coupon.php?chapter=13_4&index=3
+type RedPackRequest struct {
+	MchBillNo string
+	SendName  string
+	ReOpenId  string
+	// TotalAmount is in fen, it is shared by TotalNum users for group red packet
+	TotalAmount int64
+	TotalNum    int
+	Wishing     string
+	// ClientIp is the ip of the server calling the api, it is not used by group red packet
+	ClientIp string
+	ActName  string
+	Remark   string
+	// SceneId is required when TotalAmount is less than 1 yuan or greater than 200 yuan
+	SceneId  string
+	RiskInfo string
+	// Extra is merged into the signed parameters, it cannot override the typed fields
+	Extra map[string]string
+}
+
+// RedPackResult represent the response of sending red packet, note the field name wxappid
+type RedPackResult struct {
+	XMLName     xml.Name `xml:"xml"`
+	ReturnCode  string   `xml:"return_code"`
+	ReturnMsg   string   `xml:"return_msg"`
+	ResultCode  string   `xml:"result_code"`
+	ErrCode     string   `xml:"err_code"`
+	ErrCodeDesc string   `xml:"err_code_des"`
+	MchBillNo   string   `xml:"mch_billno"`
+	MchId       string   `xml:"mch_id"`
+	WxAppId     string   `xml:"wxappid"`
+	ReOpenId    string   `xml:"re_openid"`
+	TotalAmount string   `xml:"total_amount"`
+	SendListId  string   `xml:"send_listid"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// SendRedPack send the cash red packet to a user, TotalNum is always 1. Merchant certificate is required.
+// On SYSTEMERROR query it with GetRedPackInfo, and retry with the same MchBillNo.
+func (this *AppTrans) SendRedPack(req *RedPackRequest) (*RedPackResult, error) {
+	param, err := this.newRedPackParam(req, 1)
+	if err != nil {
+		return nil, err
+	}
+	param["client_ip"] = req.ClientIp
+
+	return this.sendRedPack(Endpoints[PathSendRedPack], param)
+}
+
+// SendGroupRedPack send the group red packet(裂变红包) shared by TotalNum(at least 3) users
+// randomly, ReOpenId is the user receiving it first. Merchant certificate is required.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/tools/cash_coupon.php?chapter=13_5&index=4
+func (this *AppTrans) SendGroupRedPack(req *RedPackRequest) (*RedPackResult, error) {
+	if req.TotalNum < 3 {
+		return nil, fmt.Errorf("total_num of group red packet must be at least 3, got:%d", req.TotalNum)
+	}
+	param, err := this.newRedPackParam(req, req.TotalNum)
+	if err != nil {
+		return nil, err
+	}
+	param["amt_type"] = "ALL_RAND"
+
+	return this.sendRedPack(Endpoints[PathSendGroupRedPack], param)
+}
+
+// newRedPackParam build the parameters shared by red packets
+func (this *AppTrans) newRedPackParam(req *RedPackRequest, totalNum int) (map[string]string, error) {
+	totalAmount, err := formatFee("total_amount", req.TotalAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	param := make(map[string]string)
+	param["wxappid"] = this.Config.AppId
+	param["mch_id"] = this.Config.MchId
+	param["mch_billno"] = req.MchBillNo
+	param["send_name"] = req.SendName
+	param["re_openid"] = req.ReOpenId
+	param["total_amount"] = totalAmount
+	param["total_num"] = strconv.Itoa(totalNum)
+	param["wishing"] = req.Wishing
+	param["act_name"] = req.ActName
+	param["remark"] = req.Remark
+	setIfNotEmpty(param, "scene_id", req.SceneId)
+	setIfNotEmpty(param, "risk_info", req.RiskInfo)
+	mergeExtra(param, req.Extra)
+
+	return param, nil
+}
+
+func (this *AppTrans) sendRedPack(ep Endpoint, param map[string]string) (*RedPackResult, error) {
+	resp, respInMap, envelope, err := this.call(context.Background(), ep, param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	result := RedPackResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RedPackReceiver is a user received the red packet
+type RedPackReceiver struct {
+	OpenId  string `xml:"openid"`
+	Amount  string `xml:"amount"`
+	RcvTime string `xml:"rcv_time"`
+}
+
+// RedPackInfo represent the response of red packet query
+type RedPackInfo struct {
+	XMLName     xml.Name `xml:"xml"`
+	ReturnCode  string   `xml:"return_code"`
+	ReturnMsg   string   `xml:"return_msg"`
+	ResultCode  string   `xml:"result_code"`
+	ErrCode     string   `xml:"err_code"`
+	ErrCodeDesc string   `xml:"err_code_des"`
+	MchBillNo   string   `xml:"mch_billno"`
+	MchId       string   `xml:"mch_id"`
+	DetailId    string   `xml:"detail_id"`
+	// Status is SENDING, SENT, FAILED, RECEIVED, RFUND_ING or REFUND
+	Status       string            `xml:"status"`
+	SendType     string            `xml:"send_type"`
+	HbType       string            `xml:"hb_type"`
+	TotalNum     string            `xml:"total_num"`
+	TotalAmount  string            `xml:"total_amount"`
+	Reason       string            `xml:"reason"`
+	SendTime     string            `xml:"send_time"`
+	RefundTime   string            `xml:"refund_time"`
+	RefundAmount string            `xml:"refund_amount"`
+	Wishing      string            `xml:"wishing"`
+	Remark       string            `xml:"remark"`
+	ActName      string            `xml:"act_name"`
+	Receivers    []RedPackReceiver `xml:"hblist>hbinfo"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// GetRedPackInfo query the red packet by mch_billno, red packets within 30 days can be queried.
+// Merchant certificate is required.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/tools/cash_coupon.php?chapter=13_6&index=5
+func (this *AppTrans) GetRedPackInfo(mchBillNo string) (*RedPackInfo, error) {
+	param := this.newParam()
+	param["mch_billno"] = mchBillNo
+	param["bill_type"] = "MCHT"
+
+	resp, respInMap, envelope, err := this.call(context.Background(), Endpoints[PathGetRedPackInfo], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	result := RedPackInfo{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
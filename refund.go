@@ -0,0 +1,240 @@
+package wxpay
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// RefundAccount is the source of refund funds, set RefundRequest.RefundAccount to refund from recharged
+// funds when the unsettled funds are not enough
+type RefundAccount string
+
+const (
+	// RefundAccountUnsettled refund from unsettled funds, the default
+	RefundAccountUnsettled RefundAccount = "REFUND_SOURCE_UNSETTLED_FUNDS"
+	// RefundAccountRecharge refund from recharged funds of the merchant
+	RefundAccountRecharge RefundAccount = "REFUND_SOURCE_RECHARGE_FUNDS"
+)
+
+// RefundRequest is the typed form of refund parameters, one of TransactionId and OutTradeNo is required
+type RefundRequest struct {
+	TransactionId string
+	OutTradeNo    string
+	OutRefundNo   string
+	TotalFee      int64
+	RefundFee     int64
+	RefundFeeType string
+	RefundDesc    string
+	RefundAccount RefundAccount
+	NotifyUrl     string
+	// Extra is merged into the signed parameters, it cannot override the typed fields
+	Extra map[string]string
+}
+
+// ToMap convert the refund request to parameters accepted by Refund, empty fields are omitted
+func (this *RefundRequest) ToMap() map[string]string {
+	param := make(map[string]string)
+	setIfNotEmpty(param, "transaction_id", this.TransactionId)
+	setIfNotEmpty(param, "out_trade_no", this.OutTradeNo)
+	setIfNotEmpty(param, "out_refund_no", this.OutRefundNo)
+	param["total_fee"] = strconv.FormatInt(this.TotalFee, 10)
+	param["refund_fee"] = strconv.FormatInt(this.RefundFee, 10)
+	setIfNotEmpty(param, "refund_fee_type", this.RefundFeeType)
+	setIfNotEmpty(param, "refund_desc", this.RefundDesc)
+	setIfNotEmpty(param, "refund_account", string(this.RefundAccount))
+	setIfNotEmpty(param, "notify_url", this.NotifyUrl)
+	mergeExtra(param, this.Extra)
+
+	return param
+}
+
+// Validate check the refund request before it is sent: both fees are valid amounts, refund_fee does not
+// exceed total_fee, out_refund_no and one of transaction_id and out_trade_no are set, refund_account is known
+func (this *RefundRequest) Validate() error {
+	if err := validateFee("total_fee", this.TotalFee); err != nil {
+		return err
+	}
+	if err := validateFee("refund_fee", this.RefundFee); err != nil {
+		return err
+	}
+	if this.RefundFee > this.TotalFee {
+		return fmt.Errorf("refund_fee %d exceed total_fee %d", this.RefundFee, this.TotalFee)
+	}
+	if this.OutRefundNo == "" {
+		return errors.New("out_refund_no is required")
+	}
+	if this.TransactionId == "" && this.OutTradeNo == "" {
+		return errors.New("one of transaction_id and out_trade_no is required")
+	}
+	if this.RefundAccount != "" && this.RefundAccount != RefundAccountUnsettled && this.RefundAccount != RefundAccountRecharge {
+		return fmt.Errorf("unknown refund_account:%s", this.RefundAccount)
+	}
+	return nil
+}
+
+// RefundOrder validate and apply the typed refund request, see Refund for more information
+func (this *AppTrans) RefundOrder(req *RefundRequest) (*RefundResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	return this.Refund(req.ToMap())
+}
+
+// RefundResult represent refund response message from weixin pay.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=9_4&index=6
+type RefundResult struct {
+	XMLName             xml.Name      `xml:"xml"`
+	ReturnCode          string        `xml:"return_code"`
+	ReturnMsg           string        `xml:"return_msg"`
+	ResultCode          string        `xml:"result_code"`
+	ErrCode             string        `xml:"err_code"`
+	ErrCodeDesc         string        `xml:"err_code_des"`
+	AppId               string        `xml:"appid"`
+	MchId               string        `xml:"mch_id"`
+	NonceStr            string        `xml:"nonce_str"`
+	Sign                string        `xml:"sign"`
+	TransactionId       string        `xml:"transaction_id"`
+	OutTradeNo          string        `xml:"out_trade_no"`
+	OutRefundNo         string        `xml:"out_refund_no"`
+	RefundId            string        `xml:"refund_id"`
+	RefundFee           string        `xml:"refund_fee"`
+	SettlementRefundFee string        `xml:"settlement_refund_fee"`
+	TotalFee            string        `xml:"total_fee"`
+	SettlementTotalFee  string        `xml:"settlement_total_fee"`
+	FeeType             string        `xml:"fee_type"`
+	CashFee             string        `xml:"cash_fee"`
+	CashFeeType         string        `xml:"cash_fee_type"`
+	CashRefundFee       string        `xml:"cash_refund_fee"`
+	CouponRefundFee     string        `xml:"coupon_refund_fee"`
+	CouponRefundCount   string        `xml:"coupon_refund_count"`
+	RefundAccount       RefundAccount `xml:"refund_account"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// Refund apply refund of a paid order, params is like RefundRequest.ToMap(), see RefundOrder for the typed one.
+// Merchant certificate is required, set CertFile and KeyFile in config.
+func (this *AppTrans) Refund(params map[string]string) (*RefundResult, error) {
+	param := this.newParam()
+	for k, v := range params {
+		param[k] = v
+	}
+
+	resp, respInMap, envelope, err := this.call(context.Background(), Endpoints[PathRefund], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	refundResult := RefundResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &refundResult); err != nil {
+		return nil, err
+	}
+	return &refundResult, nil
+}
+
+// RefundQuery is the condition of QueryRefund, at least one of the ids is required.
+// If more than one is set, weixin pay use refund_id > out_refund_no > transaction_id > out_trade_no.
+type RefundQuery struct {
+	RefundId      string
+	OutRefundNo   string
+	TransactionId string
+	OutTradeNo    string
+	// Offset page the refunds of the order when it has more than 10 refunds
+	Offset int
+}
+
+// RefundRecord is one refund of the order, parsed from the fields suffixed with _$n
+type RefundRecord struct {
+	OutRefundNo         string
+	RefundId            string
+	RefundChannel       string
+	RefundFee           string
+	SettlementRefundFee string
+	CouponRefundFee     string
+	CouponRefundCount   string
+	RefundStatus        string
+	RefundAccount       RefundAccount
+	RefundRecvAccount   string
+	RefundSuccessTime   string
+}
+
+// RefundQueryResult represent refund query response message from weixin pay.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=9_5&index=7
+type RefundQueryResult struct {
+	AppId              string
+	MchId              string
+	TotalRefundCount   int
+	TransactionId      string
+	OutTradeNo         string
+	TotalFee           string
+	SettlementTotalFee string
+	FeeType            string
+	CashFee            string
+	RefundCount        int
+	Refunds            []RefundRecord
+	// Envelope is the transport level details of the response
+	Envelope *Envelope
+}
+
+// QueryRefund query the refunds of an order
+func (this *AppTrans) QueryRefund(query *RefundQuery) (*RefundQueryResult, error) {
+	if query.RefundId == "" && query.OutRefundNo == "" && query.TransactionId == "" && query.OutTradeNo == "" {
+		return nil, errors.New("one of refund_id, out_refund_no, transaction_id and out_trade_no is required")
+	}
+
+	param := this.newParam()
+	setIfNotEmpty(param, "refund_id", query.RefundId)
+	setIfNotEmpty(param, "out_refund_no", query.OutRefundNo)
+	setIfNotEmpty(param, "transaction_id", query.TransactionId)
+	setIfNotEmpty(param, "out_trade_no", query.OutTradeNo)
+	if query.Offset > 0 {
+		param["offset"] = strconv.Itoa(query.Offset)
+	}
+
+	_, resp, envelope, err := this.call(context.Background(), Endpoints[PathRefundQuery], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(resp, envelope); err != nil {
+		return nil, err
+	}
+
+	result := &RefundQueryResult{
+		AppId:              resp["appid"],
+		MchId:              resp["mch_id"],
+		TransactionId:      resp["transaction_id"],
+		OutTradeNo:         resp["out_trade_no"],
+		TotalFee:           resp["total_fee"],
+		SettlementTotalFee: resp["settlement_total_fee"],
+		FeeType:            resp["fee_type"],
+		CashFee:            resp["cash_fee"],
+		Envelope:           envelope,
+	}
+	result.TotalRefundCount, _ = strconv.Atoi(resp["total_refund_count"])
+	result.RefundCount, _ = strconv.Atoi(resp["refund_count"])
+	for i := 0; i < result.RefundCount; i++ {
+		n := strconv.Itoa(i)
+		result.Refunds = append(result.Refunds, RefundRecord{
+			OutRefundNo:         resp["out_refund_no_"+n],
+			RefundId:            resp["refund_id_"+n],
+			RefundChannel:       resp["refund_channel_"+n],
+			RefundFee:           resp["refund_fee_"+n],
+			SettlementRefundFee: resp["settlement_refund_fee_"+n],
+			CouponRefundFee:     resp["coupon_refund_fee_"+n],
+			CouponRefundCount:   resp["coupon_refund_count_"+n],
+			RefundStatus:        resp["refund_status_"+n],
+			RefundAccount:       RefundAccount(resp["refund_account_"+n]),
+			RefundRecvAccount:   resp["refund_recv_accout_"+n],
+			RefundSuccessTime:   resp["refund_success_time_"+n],
+		})
+	}
+
+	return result, nil
+}
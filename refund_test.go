@@ -0,0 +1,36 @@
+package wxpay
+
+import "testing"
+
+func TestRefundRequestRefundAccount(t *testing.T) {
+	req := &RefundRequest{OutTradeNo: "T1", OutRefundNo: "R1", TotalFee: 100, RefundFee: 50, RefundAccount: RefundAccountRecharge}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if param := req.ToMap(); param["refund_account"] != "REFUND_SOURCE_RECHARGE_FUNDS" {
+		t.Errorf("got refund_account %q", param["refund_account"])
+	}
+	if _, ok := (&RefundRequest{OutTradeNo: "T1", OutRefundNo: "R1", TotalFee: 100, RefundFee: 50}).ToMap()["refund_account"]; ok {
+		t.Error("empty refund_account is sent")
+	}
+
+	req.RefundAccount = "REFUND_SOURCE_OTHER"
+	if err := req.Validate(); err == nil {
+		t.Error("want error for unknown refund_account")
+	}
+}
+
+func TestRefundResultRefundAccount(t *testing.T) {
+	trans := newTestGateway(t, replyXml(map[string]string{
+		"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100",
+		"out_refund_no": "R1", "refund_fee": "50", "refund_account": "REFUND_SOURCE_RECHARGE_FUNDS",
+	}, true))
+
+	result, err := trans.RefundOrder(&RefundRequest{OutTradeNo: "T1", OutRefundNo: "R1", TotalFee: 100, RefundFee: 50, RefundAccount: RefundAccountRecharge})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RefundAccount != RefundAccountRecharge {
+		t.Errorf("got refund account %s", result.RefundAccount)
+	}
+}
@@ -0,0 +1,97 @@
+package wxpay
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+)
+
+// RefundNotification represent the decrypted req_info of refund result notification.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=9_16&index=11
+type RefundNotification struct {
+	XMLName             xml.Name `xml:"root"`
+	AppId               string   `xml:"-"`
+	MchId               string   `xml:"-"`
+	TransactionId       string   `xml:"transaction_id"`
+	OutTradeNo          string   `xml:"out_trade_no"`
+	RefundId            string   `xml:"refund_id"`
+	OutRefundNo         string   `xml:"out_refund_no"`
+	TotalFee            string   `xml:"total_fee"`
+	SettlementTotalFee  string   `xml:"settlement_total_fee"`
+	RefundFee           string   `xml:"refund_fee"`
+	SettlementRefundFee string   `xml:"settlement_refund_fee"`
+	// RefundStatus is SUCCESS, CHANGE(refund failed, handle it in merchant platform) or REFUNDCLOSE
+	RefundStatus        string        `xml:"refund_status"`
+	SuccessTime         string        `xml:"success_time"`
+	RefundRecvAccount   string        `xml:"refund_recv_accout"`
+	RefundAccount       RefundAccount `xml:"refund_account"`
+	RefundRequestSource string        `xml:"refund_request_source"`
+}
+
+// DecryptRefundNotify parse the refund result notification in body of the request to notify_url of refund.
+// The notification is not signed, its req_info is encrypted with the api key instead, and decrypted here.
+func (this *AppTrans) DecryptRefundNotify(body []byte) (*RefundNotification, error) {
+	notifyInMap, err := this.parseXmlMap(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if notifyInMap["return_code"] != "SUCCESS" {
+		return nil, fmt.Errorf("return code:%s, return desc:%s", notifyInMap["return_code"], notifyInMap["return_msg"])
+	}
+
+	if err := this.checkNotifyMerchant(notifyInMap); err != nil {
+		return nil, err
+	}
+
+	key, err := this.appKey()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := DecryptReqInfo(notifyInMap["req_info"], key)
+	if err != nil {
+		return nil, err
+	}
+
+	notification := &RefundNotification{}
+	if err := this.unmarshalXml(plain, notification); err != nil {
+		return nil, err
+	}
+	notification.AppId = notifyInMap["appid"]
+	notification.MchId = notifyInMap["mch_id"]
+
+	return notification, nil
+}
+
+// DecryptReqInfo decrypt the base64 encoded req_info with AES-256-ECB,
+// whose key is the lowercase hex md5 of api key, and remove the PKCS#7 padding
+func DecryptReqInfo(reqInfo string, key string) ([]byte, error) {
+	cipherText, err := base64.StdEncoding.DecodeString(reqInfo)
+	if err != nil {
+		return nil, fmt.Errorf("req_info is not base64: %v", err)
+	}
+
+	block, err := aes.NewCipher([]byte(fmt.Sprintf("%x", md5.Sum([]byte(key)))))
+	if err != nil {
+		return nil, err
+	}
+	size := block.BlockSize()
+	if len(cipherText) == 0 || len(cipherText)%size != 0 {
+		return nil, fmt.Errorf("req_info length %d is not multiple of block size", len(cipherText))
+	}
+
+	plain := make([]byte, len(cipherText))
+	for i := 0; i < len(cipherText); i += size {
+		block.Decrypt(plain[i:i+size], cipherText[i:i+size])
+	}
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > size || !bytes.Equal(plain[len(plain)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("req_info has invalid padding, check the api key")
+	}
+
+	return plain[:len(plain)-padding], nil
+}
@@ -0,0 +1,63 @@
+package wxpay
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"testing"
+)
+
+// encryptReqInfo is the reverse of DecryptReqInfo, as weixin pay encrypt req_info
+func encryptReqInfo(plain []byte, key string) string {
+	block, err := aes.NewCipher([]byte(fmt.Sprintf("%x", md5.Sum([]byte(key)))))
+	if err != nil {
+		panic(err)
+	}
+	size := block.BlockSize()
+	padding := size - len(plain)%size
+	plain = append(plain, bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	cipherText := make([]byte, len(plain))
+	for i := 0; i < len(plain); i += size {
+		block.Encrypt(cipherText[i:i+size], plain[i:i+size])
+	}
+	return base64.StdEncoding.EncodeToString(cipherText)
+}
+
+func TestDecryptReqInfo(t *testing.T) {
+	for _, plain := range []string{"", "0123456789abcdef", "<root><out_refund_no>R1</out_refund_no></root>"} {
+		got, err := DecryptReqInfo(encryptReqInfo([]byte(plain), signExampleKey), signExampleKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != plain {
+			t.Errorf("got %q, want %q", got, plain)
+		}
+	}
+}
+
+func TestDecryptReqInfoWrongKey(t *testing.T) {
+	reqInfo := encryptReqInfo([]byte("<root></root>"), signExampleKey)
+	if plain, err := DecryptReqInfo(reqInfo, "wrong key"); err == nil {
+		t.Errorf("want error, got %q", plain)
+	}
+	if _, err := DecryptReqInfo("not base64!", signExampleKey); err == nil {
+		t.Error("want error for invalid base64")
+	}
+}
+
+func TestDecryptRefundNotify(t *testing.T) {
+	trans := &AppTrans{Config: &WxConfig{AppId: "wx1", MchId: "100", AppKey: signExampleKey}}
+	reqInfo := encryptReqInfo([]byte("<root><out_refund_no>R1</out_refund_no><refund_status>SUCCESS</refund_status></root>"), signExampleKey)
+	body := ToXmlString(map[string]string{"return_code": "SUCCESS", "appid": "wx1", "mch_id": "100", "req_info": reqInfo})
+
+	notification, err := trans.DecryptRefundNotify([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notification.OutRefundNo != "R1" || notification.RefundStatus != "SUCCESS" || notification.MchId != "100" {
+		t.Errorf("unexpected notification: %+v", notification)
+	}
+}
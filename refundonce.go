@@ -0,0 +1,107 @@
+package wxpay
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// maxOutRefundNoLength is the max length of out_refund_no accepted by weixin pay
+const maxOutRefundNoLength = 64
+
+// DeriveOutRefundNo return the out_refund_no of the seq-th(from 1) partial refund of the order, so the same
+// refund always get the same out_refund_no and weixin pay deduplicate its retries. Order id too long to
+// keep is replaced with its md5.
+func DeriveOutRefundNo(orderId string, seq int) string {
+	suffix := fmt.Sprintf("-R%d", seq)
+	if len(orderId)+len(suffix) > maxOutRefundNoLength {
+		orderId = fmt.Sprintf("%x", md5.Sum([]byte(orderId)))
+	}
+	return orderId + suffix
+}
+
+// RefundOnce apply the seq-th(from 1) partial refund of the order idempotently, retry it with the same
+// req and seq until the outcome is known. OutRefundNo is derived with DeriveOutRefundNo if it is empty.
+// WxConfig.Journal is required: the refund is refused if its out_refund_no was journaled for a different
+// request(e.g. another refund_fee), so a retry never become another refund. Calls of the same out_refund_no
+// are serialized from the check until the refund is journaled, only within this AppTrans though, calls of
+// multiple instances should be serialized by the caller, e.g. with a Locker on out_refund_no.
+func (this *AppTrans) RefundOnce(req *RefundRequest, seq int) (*RefundResult, error) {
+	if this.Config.Journal == nil {
+		return nil, errors.New("journal is required for idempotent refund, set WxConfig.Journal")
+	}
+	if seq < 1 {
+		return nil, fmt.Errorf("seq of refund should start from 1, got:%d", seq)
+	}
+
+	refundReq := *req
+	if refundReq.OutRefundNo == "" {
+		orderId := refundReq.OutTradeNo
+		if orderId == "" {
+			orderId = refundReq.TransactionId
+		}
+		refundReq.OutRefundNo = DeriveOutRefundNo(orderId, seq)
+	}
+	if err := refundReq.Validate(); err != nil {
+		return nil, err
+	}
+
+	unlock := this.refundLocks.lock(refundReq.OutRefundNo)
+	defer unlock()
+
+	param := this.newParam()
+	for k, v := range refundReq.ToMap() {
+		param[k] = v
+	}
+	entries, err := this.Config.Journal.Entries(PathRefund, refundReq.OutRefundNo)
+	if err != nil {
+		return nil, fmt.Errorf("journal not available, refund is not sent: %v", err)
+	}
+	hash := journalRequestHash(param)
+	for _, entry := range entries {
+		if entry.RequestHash != hash {
+			return nil, fmt.Errorf("out_refund_no %s is used by a different refund request", refundReq.OutRefundNo)
+		}
+	}
+
+	return this.Refund(refundReq.ToMap())
+}
+
+// keyedMutex is a set of mutexes by key, a mutex is dropped once no one holds or waits for it
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock acquire the mutex of key, it return the function to release it
+func (this *keyedMutex) lock(key string) func() {
+	this.mu.Lock()
+	if this.locks == nil {
+		this.locks = make(map[string]*keyedLock)
+	}
+	l, ok := this.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		this.locks[key] = l
+	}
+	l.refs++
+	this.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		this.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(this.locks, key)
+		}
+		this.mu.Unlock()
+	}
+}
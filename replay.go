@@ -0,0 +1,149 @@
+package wxpay
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ChinaTimeZone is the time zone(UTC+8:00) of time fields like time_end
+var ChinaTimeZone = time.FixedZone("CST", ChinaTimeZoneOffset)
+
+const timeEndLayout = "20060102150405"
+
+// NonceStore remember the notifications already seen.
+// Use a shared implementation(e.g. redis SETNX with expire) when running multiple instances.
+type NonceStore interface {
+	// Add record the key for ttl, it return false if the key is already recorded
+	Add(key string, ttl time.Duration) (bool, error)
+	// Remove forget the key, so the notification is accepted when it is sent again
+	Remove(key string) error
+}
+
+// MemoryNonceStore is a NonceStore in process memory, expired keys are dropped on Add
+type MemoryNonceStore struct {
+	mu    sync.Mutex
+	items map[string]time.Time
+}
+
+// NewMemoryNonceStore return an empty MemoryNonceStore
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{items: make(map[string]time.Time)}
+}
+
+func (this *MemoryNonceStore) Add(key string, ttl time.Duration) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	now := time.Now()
+	for k, expire := range this.items {
+		if now.After(expire) {
+			delete(this.items, k)
+		}
+	}
+
+	if _, ok := this.items[key]; ok {
+		return false, nil
+	}
+	this.items[key] = now.Add(ttl)
+
+	return true, nil
+}
+
+func (this *MemoryNonceStore) Remove(key string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	delete(this.items, key)
+	return nil
+}
+
+// ReplayError is returned when a notification is considered as replay
+type ReplayError struct {
+	// Duplicate is true if the (nonce_str, out_trade_no) pair has been seen,
+	// otherwise the notification is out of the time window
+	Duplicate bool
+	Reason    string
+}
+
+func (this *ReplayError) Error() string {
+	return "replayed notification: " + this.Reason
+}
+
+// NotifyRetryPeriod is how long weixin pay resend a notification not acknowledged, counted from time_end
+const NotifyRetryPeriod = 24 * time.Hour
+
+// ReplayGuard reject notifications which are too old or have been seen before, set it to
+// WxConfig.ReplayGuard to check notifications in NotifyHandler.
+// Note weixin pay resend the same notification until it is acknowledged, so a duplicate
+// of an already processed notification should still be answered with SUCCESS.
+type ReplayGuard struct {
+	// Window is the max age of time_end(or timestamp) of notification, zero disable the check.
+	// It must be longer than NotifyRetryPeriod, otherwise the late retries of a notification not
+	// acknowledged yet are rejected, and the order is never credited.
+	Window time.Duration
+	// Store record the (nonce_str, out_trade_no) pairs, nil disable the check. The pairs are kept
+	// for Window, or NotifyRetryPeriod if Window is zero.
+	Store NonceStore
+}
+
+// Check the notification in form of map, return *ReplayError if it is a replay
+func (this *ReplayGuard) Check(notify map[string]string) error {
+	if this.Window > 0 {
+		sentAt, err := notifyTime(notify)
+		if err != nil {
+			return err
+		}
+		if age := time.Since(sentAt); age > this.Window || age < -this.Window {
+			return &ReplayError{Reason: fmt.Sprintf("time %s out of window %s", sentAt, this.Window)}
+		}
+	}
+
+	if this.Store != nil {
+		key := replayKey(notify)
+		ttl := this.Window
+		if ttl <= 0 {
+			ttl = NotifyRetryPeriod
+		}
+		added, err := this.Store.Add(key, ttl)
+		if err != nil {
+			return err
+		}
+		if !added {
+			return &ReplayError{Duplicate: true, Reason: "seen " + key}
+		}
+	}
+
+	return nil
+}
+
+// Forget remove the notification from Store, so it is accepted when sent again, e.g. after handling it failed
+func (this *ReplayGuard) Forget(notify map[string]string) error {
+	if this.Store == nil {
+		return nil
+	}
+	return this.Store.Remove(replayKey(notify))
+}
+
+// replayKey return the key of notification in NonceStore
+func replayKey(notify map[string]string) string {
+	return notify["nonce_str"] + ":" + notify["out_trade_no"]
+}
+
+// notifyTime return the time of notification from time_end or timestamp field
+func notifyTime(notify map[string]string) (time.Time, error) {
+	if timeEnd := notify["time_end"]; timeEnd != "" {
+		return time.ParseInLocation(timeEndLayout, timeEnd, ChinaTimeZone)
+	}
+
+	if timestamp := notify["timestamp"]; timestamp != "" {
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(sec, 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("notification has neither time_end nor timestamp")
+}
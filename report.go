@@ -0,0 +1,117 @@
+package wxpay
+
+import (
+	"fmt"
+)
+
+// DailySettlementReport is the summary of a day combined from the trade bill, fund flow bill of
+// basic account and optionally the settlement query, for finance dashboards
+type DailySettlementReport struct {
+	Date string
+	// Gross is the settlement total fee of orders, Net is Gross minus Refunds and Fees rounded to fen
+	Gross   Fen
+	Refunds Fen
+	Fees    Poundage
+	Net     Fen
+	// FundIncome and FundExpend is the total of fund flow bill of basic account, their difference is
+	// checked with Net. Flows not from trades, e.g. withdrawals, are reported as mismatch.
+	FundIncome Fen
+	FundExpend Fen
+	// Settled is the settlement fee of batches settled on the date in the settlement currency, only for
+	// cross-border merchants. Batches settle trades of earlier days, so it is not checked with Net.
+	Settled CurrencyAmount
+	// Mismatches describe the totals failed the cross check, empty if all match
+	Mismatches []string
+}
+
+// DailySettlementReport download the bills of date(yyyyMMdd) and cross check their totals, the trade bill
+// rows with its summary, and the net of trade bill with the fund flow of basic account. The settlement
+// query is only available to cross-border merchants, set querySettlement to include it.
+func (this *AppTrans) DailySettlementReport(date string, querySettlement bool) (*DailySettlementReport, error) {
+	bill, err := this.DownloadBill(date, BillTypeAll)
+	if err != nil {
+		return nil, err
+	}
+	records, summary, err := bill.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DailySettlementReport{
+		Date:    date,
+		Gross:   summary.SettlementTotalFee,
+		Refunds: summary.RefundFee,
+		Fees:    summary.PoundageFee,
+	}
+	report.Net = report.Gross - report.Refunds - report.Fees.Fen()
+
+	var gross, refunds Fen
+	var fees Poundage
+	for _, record := range records {
+		if record.IsRefund() {
+			refunds += record.RefundFee
+		} else {
+			gross += record.SettlementTotalFee
+		}
+		fees += record.PoundageFee
+	}
+	report.check("trade bill gross", gross, report.Gross)
+	report.check("trade bill refunds", refunds, report.Refunds)
+	if fees != report.Fees {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("trade bill fees is %s, but %s is reported", fees, report.Fees))
+	}
+	if len(records) != summary.TotalCount {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("trade bill has %d records, summary says %d", len(records), summary.TotalCount))
+	}
+
+	_, fundSummary, err := this.DownloadFundFlow(date, FundAccountBasic)
+	if err != nil {
+		return nil, err
+	}
+	report.FundIncome = fundSummary.Income
+	report.FundExpend = fundSummary.Expend
+	report.check("fund flow income minus expense", report.FundIncome-report.FundExpend, report.Net)
+
+	if querySettlement {
+		if err := this.addSettled(report); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// addSettled sum the settlement fee of batches settled on the date of report
+func (this *AppTrans) addSettled(report *DailySettlementReport) error {
+	const limit = 10
+	for offset := 0; ; offset += limit {
+		result, err := this.QuerySettlement(true, report.Date, report.Date, offset, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range result.Records {
+			if record.DateSettlement != report.Date {
+				continue
+			}
+			if report.Settled.FeeType == "" {
+				report.Settled.FeeType = record.SettlementFee.FeeType
+			}
+			settled, err := report.Settled.Add(record.SettlementFee)
+			if err != nil {
+				return fmt.Errorf("settlement of batch %s: %v", record.BatchNo, err)
+			}
+			report.Settled = settled
+		}
+		if len(result.Records) < limit {
+			return nil
+		}
+	}
+}
+
+// check record a mismatch if the computed total differ from the reported one
+func (this *DailySettlementReport) check(name string, computed, reported Fen) {
+	if computed != reported {
+		this.Mismatches = append(this.Mismatches, fmt.Sprintf("%s is %s, but %s is reported", name, computed, reported))
+	}
+}
@@ -0,0 +1,59 @@
+package wxpay
+
+import (
+	"strings"
+	"testing"
+)
+
+func testFundFlow(expend string) string {
+	return "记账时间,微信支付业务单号,资金流水单号,业务名称,业务类型,收支类型,收支金额（元）,账户结余（元）,资金变更提交申请人,备注,业务凭证号\r\n" +
+		"`2024-01-02 10:20:30,`4200001,`F1,`交易,`交易,`收入,`1.00,`1.00,`system,`,`\r\n" +
+		"`2024-01-02 11:00:00,`5000001,`F2,`退款,`退款,`支出,`" + expend + ",`0.50,`system,`,`\r\n" +
+		"资金流水总笔数,收入笔数,收入金额,支出笔数,支出金额\r\n" +
+		"`2,`1,`1.00,`1,`" + expend + "\r\n"
+}
+
+// replyReport answer the downloads and settlement query of DailySettlementReport
+func replyReport(fundFlow string) func(string, map[string]string) string {
+	settlement := map[string]string{
+		"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100", "record_num": "2",
+		"fbatchno_0": "B1", "date_settlement_0": "20240102", "settlementfee_type_0": "USD", "settlement_fee_0": "1000",
+		"fbatchno_1": "B2", "date_settlement_1": "20240102", "settlementfee_type_1": "USD", "settlement_fee_1": "234",
+	}
+
+	return func(path string, param map[string]string) string {
+		switch path {
+		case PathDownloadBill:
+			return testBill
+		case PathDownloadFundFlow:
+			return fundFlow
+		}
+		return signedXml(settlement)
+	}
+}
+
+func TestDailySettlementReport(t *testing.T) {
+	report, err := newTestGateway(t, replyReport(testFundFlow("0.50"))).DailySettlementReport("20240102", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Gross != 100 || report.Refunds != 50 || report.Fees != 300 || report.Net != 50 {
+		t.Errorf("unexpected totals: %+v", report)
+	}
+	if len(report.Mismatches) != 0 {
+		t.Errorf("unexpected mismatches: %v", report.Mismatches)
+	}
+	if report.Settled != (CurrencyAmount{Amount: 1234, FeeType: "USD"}) {
+		t.Errorf("settled %s, want 12.34 USD", report.Settled)
+	}
+}
+
+func TestDailySettlementReportFundFlowMismatch(t *testing.T) {
+	report, err := newTestGateway(t, replyReport(testFundFlow("0.60"))).DailySettlementReport("20240102", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Mismatches) != 1 || !strings.Contains(report.Mismatches[0], "fund flow") {
+		t.Errorf("unexpected mismatches: %v", report.Mismatches)
+	}
+}
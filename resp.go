@@ -21,6 +21,9 @@ type PlaceOrderResult struct {
 	TradeType   string   `xml:"trade_type"`
 	PrepayId    string   `xml:"prepay_id"`
 	CodeUrl     string   `xml:"code_url"`
+	MwebUrl     string   `xml:"mweb_url"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
 }
 
 func (this *PlaceOrderResult) ToMap() map[string]string {
@@ -35,7 +38,7 @@ func (this *PlaceOrderResult) ToMap() map[string]string {
 // Parse the reponse message from weixin pay to struct of PlaceOrderResult
 func ParsePlaceOrderResult(resp []byte) (PlaceOrderResult, error) {
 	placeOrderResult := PlaceOrderResult{}
-	err := xml.Unmarshal(resp, &placeOrderResult)
+	err := unmarshalXml(resp, &placeOrderResult)
 	if err != nil {
 		return placeOrderResult, err
 	}
@@ -46,33 +49,38 @@ func ParsePlaceOrderResult(resp []byte) (PlaceOrderResult, error) {
 // QueryOrder Result represent query response message from weixin pay
 // Refer to http://pay.weixin.qq.com/wiki/doc/api/app.php?chapter=9_2&index=4
 type QueryOrderResult struct {
-	XMLName        xml.Name `xml:"xml"`
-	ReturnCode     string   `xml:"return_code"`
-	ReturnMsg      string   `xml:"return_msg"`
-	AppId          string   `xml:"appid"`
-	MchId          string   `xml:"mch_id"`
-	NonceStr       string   `xml:"nonce_str"`
-	Sign           string   `xml:"sign"`
-	ResultCode     string   `xml:"result_code"`
-	ErrCode        string   `xml:"err_code"`
-	ErrCodeDesc    string   `xml:"err_code_des"`
-	DeviceInfo     string   `xml:"device_info"`
-	OpenId         string   `xml:"open_id"`
-	IsSubscribe    string   `xml:"is_subscribe"`
-	TradeType      string   `xml:"trade_type"`
-	TradeState     string   `xml:"trade_state"`
-	TradeStateDesc string   `xml:"trade_state_desc"`
-	BankType       string   `xml:"bank_type"`
-	TotalFee       string   `xml:"total_fee"`
-	FeeType        string   `xml:"fee_type"`
-	CashFee        string   `xml:"cash_fee"`
-	CashFeeType    string   `xml:"cash_fee_type"`
-	CouponFee      string   `xml:"coupon_fee"`
-	CouponCount    string   `xml:"coupon_count"`
-	TransactionId  string   `xml:"transaction_id"`
-	OrderId        string   `xml:"out_trade_no"`
-	Attach         string   `xml:"attach"`
-	TimeEnd        string   `xml:"time_end"`
+	XMLName            xml.Name `xml:"xml"`
+	ReturnCode         string   `xml:"return_code"`
+	ReturnMsg          string   `xml:"return_msg"`
+	AppId              string   `xml:"appid"`
+	MchId              string   `xml:"mch_id"`
+	NonceStr           string   `xml:"nonce_str"`
+	Sign               string   `xml:"sign"`
+	ResultCode         string   `xml:"result_code"`
+	ErrCode            string   `xml:"err_code"`
+	ErrCodeDesc        string   `xml:"err_code_des"`
+	DeviceInfo         string   `xml:"device_info"`
+	OpenId             string   `xml:"openid"`
+	IsSubscribe        string   `xml:"is_subscribe"`
+	TradeType          string   `xml:"trade_type"`
+	TradeState         string   `xml:"trade_state"`
+	TradeStateDesc     string   `xml:"trade_state_desc"`
+	BankType           string   `xml:"bank_type"`
+	TotalFee           string   `xml:"total_fee"`
+	SettlementTotalFee string   `xml:"settlement_total_fee"`
+	FeeType            string   `xml:"fee_type"`
+	CashFee            string   `xml:"cash_fee"`
+	CashFeeType        string   `xml:"cash_fee_type"`
+	CouponFee          string   `xml:"coupon_fee"`
+	CouponCount        string   `xml:"coupon_count"`
+	TransactionId      string   `xml:"transaction_id"`
+	OrderId            string   `xml:"out_trade_no"`
+	Attach             string   `xml:"attach"`
+	TimeEnd            string   `xml:"time_end"`
+	// PromotionDetail is in json, use Promotions to parse it
+	PromotionDetail string `xml:"promotion_detail"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
 }
 
 func (this *QueryOrderResult) ToMap() map[string]string {
@@ -84,9 +92,24 @@ func (this *QueryOrderResult) ToMap() map[string]string {
 	return retMap
 }
 
+// BankName return the human readable name of bank_type
+func (this *QueryOrderResult) BankName() string {
+	return BankName(this.BankType)
+}
+
+// Amounts return the typed amounts of the order
+func (this *QueryOrderResult) Amounts() (OrderAmounts, error) {
+	return parseOrderAmounts(this.TotalFee, this.SettlementTotalFee, this.CashFee, this.CouponFee)
+}
+
+// Promotions return the parsed promotion_detail of the order
+func (this *QueryOrderResult) Promotions() ([]PromotionDetail, error) {
+	return ParsePromotionDetail(this.PromotionDetail)
+}
+
 func ParseQueryOrderResult(resp []byte) (QueryOrderResult, error) {
 	queryOrderResult := QueryOrderResult{}
-	err := xml.Unmarshal(resp, &queryOrderResult)
+	err := unmarshalXml(resp, &queryOrderResult)
 	if err != nil {
 		return queryOrderResult, err
 	}
@@ -0,0 +1,83 @@
+package wxpay
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// sandboxPathPrefix is inserted before the path of apis in sandbox mode
+const sandboxPathPrefix = "/sandboxnew"
+
+// Environment tell whether the client is calling the sandbox or production apis of weixin pay
+type Environment string
+
+const (
+	// EnvironmentProduction is the real apis moving real money
+	EnvironmentProduction Environment = "production"
+	// EnvironmentSandbox is the sandbox apis enabled by WxConfig.Sandbox
+	EnvironmentSandbox Environment = "sandbox"
+)
+
+// Environment return EnvironmentSandbox if WxConfig.Sandbox is set, or EnvironmentProduction.
+// It is stamped on envelopes, journal entries and logs, so test traffic can be told apart.
+func (this *AppTrans) Environment() Environment {
+	if this.Config.Sandbox {
+		return EnvironmentSandbox
+	}
+	return EnvironmentProduction
+}
+
+// sandboxUrl rewrite the api url to the sandbox one in sandbox mode. Error is returned if the url
+// cannot be rewritten, a sandbox client never call the production apis, e.g. to refund real money.
+func (this *AppTrans) sandboxUrl(targetUrl string) (string, error) {
+	if !this.Config.Sandbox {
+		return targetUrl, nil
+	}
+
+	parsed, err := url.Parse(targetUrl)
+	if err != nil {
+		return "", fmt.Errorf("sandbox client refuse to call %s: %v", targetUrl, err)
+	}
+	if strings.HasPrefix(parsed.Path, sandboxPathPrefix+"/") {
+		return targetUrl, nil
+	}
+	parsed.Path = sandboxPathPrefix + parsed.Path
+	return parsed.String(), nil
+}
+
+// sandboxSignKey return the sign key of sandbox, it is fetched with the api key once and cached until Close
+func (this *AppTrans) sandboxSignKey() (string, error) {
+	this.sandboxMu.Lock()
+	defer this.sandboxMu.Unlock()
+
+	if this.sandboxKey != "" {
+		return this.sandboxKey, nil
+	}
+
+	key, err := this.merchantKey()
+	if err != nil {
+		return "", err
+	}
+	param := map[string]string{"mch_id": this.Config.MchId, "nonce_str": NewNonceString()}
+	param["sign"] = Sign(param, key)
+
+	resp, _, err := this.post(context.Background(), this.httpClient(), this.apiUrl(PathSandboxSignKey), []byte(ToXmlString(param)))
+	if err != nil {
+		return "", err
+	}
+	respInMap, err := this.parseXmlMap(resp)
+	if err != nil {
+		return "", err
+	}
+	if respInMap["return_code"] != "SUCCESS" {
+		return "", fmt.Errorf("return code:%s, return desc:%s", respInMap["return_code"], respInMap["return_msg"])
+	}
+	if respInMap["sandbox_signkey"] == "" {
+		return "", fmt.Errorf("sandbox_signkey is missing in response")
+	}
+
+	this.sandboxKey = respInMap["sandbox_signkey"]
+	return this.sandboxKey, nil
+}
@@ -0,0 +1,28 @@
+package wxpay
+
+import "testing"
+
+func TestSandboxUrl(t *testing.T) {
+	trans := &AppTrans{Config: &WxConfig{Sandbox: true}}
+	if env := trans.Environment(); env != EnvironmentSandbox {
+		t.Errorf("got environment %s", env)
+	}
+
+	for _, targetUrl := range []string{DefaultApiHost + PathRefund, DefaultApiHost + sandboxPathPrefix + PathRefund} {
+		got, err := trans.sandboxUrl(targetUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := DefaultApiHost + sandboxPathPrefix + PathRefund; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	if _, err := trans.sandboxUrl("://bad"); err == nil {
+		t.Error("want error for url cannot be rewritten")
+	}
+
+	trans.Config.Sandbox = false
+	if got, _ := trans.sandboxUrl(DefaultApiHost + PathRefund); got != DefaultApiHost+PathRefund {
+		t.Errorf("production url is rewritten: %s", got)
+	}
+}
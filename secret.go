@@ -0,0 +1,71 @@
+package wxpay
+
+import (
+	"io"
+)
+
+// SecretProvider provide the api key on demand, e.g. from KMS or vault,
+// so the key needs not be kept in WxConfig as plain field
+type SecretProvider interface {
+	AppKey() (string, error)
+}
+
+// appKey return the key to sign requests and verify responses with,
+// it is the sandbox sign key in sandbox mode, or the api key otherwise
+func (this *AppTrans) appKey() (string, error) {
+	if this.Config.Sandbox {
+		return this.sandboxSignKey()
+	}
+	return this.merchantKey()
+}
+
+// merchantKey return the api key from WxConfig.Secrets if set, or WxConfig.AppKey.
+// Key from Secrets is fetched once and cached until Close.
+func (this *AppTrans) merchantKey() (string, error) {
+	if this.Config.Secrets == nil {
+		return this.Config.AppKey, nil
+	}
+
+	this.keyMu.Lock()
+	defer this.keyMu.Unlock()
+
+	if this.key == nil {
+		key, err := this.Config.Secrets.AppKey()
+		if err != nil {
+			return "", err
+		}
+		this.key = []byte(key)
+	}
+
+	return string(this.key), nil
+}
+
+// Close wipe the key cached from WxConfig.Secrets and the sandbox sign key, release the connections of the
+// merchant certificate client, and close Secrets if it is an io.Closer. The key and certificate are loaded
+// again on next use. AppTrans starts no goroutine, background workers like OutboxDispatcher are stopped by
+// their own Shutdown or ctx. Note the string copies made for signing
+// cannot be wiped and are left to the garbage collector.
+func (this *AppTrans) Close() error {
+	this.keyMu.Lock()
+	for i := range this.key {
+		this.key[i] = 0
+	}
+	this.key = nil
+	this.keyMu.Unlock()
+
+	this.sandboxMu.Lock()
+	this.sandboxKey = ""
+	this.sandboxMu.Unlock()
+
+	this.certMu.Lock()
+	if this.certHttp != nil {
+		this.certHttp.CloseIdleConnections()
+		this.certHttp = nil
+	}
+	this.certMu.Unlock()
+
+	if closer, ok := this.Config.Secrets.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package wxpay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// SettlementRecord is one settlement batch of cross-border merchant, amounts are in SettlementFeeType
+type SettlementRecord struct {
+	BatchNo           string
+	DateSettlement    string
+	DateStart         string
+	DateEnd           string
+	SettlementFeeType string
+	SettlementFee     CurrencyAmount
+	UnsettlementFee   CurrencyAmount
+	PayFee            CurrencyAmount
+	RefundFee         CurrencyAmount
+	PayNetFee         CurrencyAmount
+	PoundageFee       CurrencyAmount
+}
+
+// SettlementQueryResult represent the response of settlement query
+type SettlementQueryResult struct {
+	RecordNum int
+	Records   []SettlementRecord
+	// Envelope is the transport level details of the response
+	Envelope *Envelope
+}
+
+// QuerySettlement query the settled(or unsettled if settled is false) funds of cross-border merchant
+// between dateStart and dateEnd in form of yyyyMMdd. At most 10 records are returned at once, page with offset.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/external/app.php?chapter=9_14&index=7
+func (this *AppTrans) QuerySettlement(settled bool, dateStart, dateEnd string, offset, limit int) (*SettlementQueryResult, error) {
+	param := this.newParam()
+	param["usetag"] = "2"
+	if settled {
+		param["usetag"] = "1"
+	}
+	param["date_start"] = dateStart
+	param["date_end"] = dateEnd
+	param["offset"] = strconv.Itoa(offset)
+	param["limit"] = strconv.Itoa(limit)
+
+	_, resp, envelope, err := this.call(context.Background(), Endpoints[PathSettlementQuery], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(resp, envelope); err != nil {
+		return nil, err
+	}
+
+	result := &SettlementQueryResult{Envelope: envelope}
+	result.RecordNum, _ = strconv.Atoi(resp["record_num"])
+	for i := 0; i < result.RecordNum; i++ {
+		record, err := parseSettlementRecord(resp, strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		result.Records = append(result.Records, record)
+	}
+
+	return result, nil
+}
+
+// parseSettlementRecord parse the n-th record of settlement query response
+func parseSettlementRecord(resp map[string]string, n string) (SettlementRecord, error) {
+	record := SettlementRecord{
+		BatchNo:           resp["fbatchno_"+n],
+		DateSettlement:    resp["date_settlement_"+n],
+		DateStart:         resp["date_start_"+n],
+		DateEnd:           resp["date_end_"+n],
+		SettlementFeeType: resp["settlementfee_type_"+n],
+	}
+
+	amounts := []struct {
+		name   string
+		amount *CurrencyAmount
+	}{
+		{"settlement_fee", &record.SettlementFee},
+		{"unsettlement_fee", &record.UnsettlementFee},
+		{"pay_fee", &record.PayFee},
+		{"refund_fee", &record.RefundFee},
+		{"pay_net_fee", &record.PayNetFee},
+		{"poundage_fee", &record.PoundageFee},
+	}
+	for _, a := range amounts {
+		amount, err := ParseCurrencyAmount(resp[a.name+"_"+n], record.SettlementFeeType)
+		if err != nil {
+			return record, fmt.Errorf("invalid %s of batch %s: %v", a.name, record.BatchNo, err)
+		}
+		*a.amount = amount
+	}
+	return record, nil
+}
+
+// ExchangeRate is the rate of foreign currency to CNY on a date, scaled by RateScale
+type ExchangeRate struct {
+	FeeType  string
+	RateTime string
+	Rate     int64
+	// Envelope is the transport level details of the response
+	Envelope *Envelope
+}
+
+// ToCny convert amount in FeeType to CNY fen with the rate, see ConvertToCny
+func (this *ExchangeRate) ToCny(amount CurrencyAmount) (Fen, error) {
+	if amount.FeeType != this.FeeType {
+		return 0, fmt.Errorf("amount in %s is converted with rate of %s", amount.FeeType, this.FeeType)
+	}
+	fen, err := ConvertToCny(amount.Amount, this.FeeType, this.Rate)
+	return Fen(fen), err
+}
+
+// QueryExchangeRate query the exchange rate of feeType to CNY on date(yyyyMMdd) for cross-border merchant.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/external/app.php?chapter=9_15&index=8
+func (this *AppTrans) QueryExchangeRate(feeType string, date string) (*ExchangeRate, error) {
+	param := this.newParam()
+	param["fee_type"] = feeType
+	param["date"] = date
+
+	_, resp, envelope, err := this.call(context.Background(), Endpoints[PathQueryExchangeRate], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(resp, envelope); err != nil {
+		return nil, err
+	}
+
+	rate, err := strconv.ParseInt(resp["rate"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate:%s", resp["rate"])
+	}
+	return &ExchangeRate{FeeType: feeType, RateTime: resp["rate_time"], Rate: rate, Envelope: envelope}, nil
+}
@@ -0,0 +1,59 @@
+package wxpay
+
+import "testing"
+
+func TestCurrencyAmountString(t *testing.T) {
+	cases := map[CurrencyAmount]string{
+		{Amount: 1234, FeeType: "USD"}:  "12.34 USD",
+		{Amount: -5, FeeType: "HKD"}:    "-0.05 HKD",
+		{Amount: 1234, FeeType: "JPY"}:  "1234 JPY",
+		{Amount: 12345, FeeType: "KWD"}: "12.345 KWD",
+	}
+	for amount, want := range cases {
+		if got := amount.String(); got != want {
+			t.Errorf("%+v formatted as %s, want %s", amount, got, want)
+		}
+	}
+
+	if _, err := (CurrencyAmount{Amount: 1, FeeType: "USD"}).Add(CurrencyAmount{Amount: 1, FeeType: "JPY"}); err == nil {
+		t.Error("want error adding different currencies")
+	}
+}
+
+func TestQuerySettlement(t *testing.T) {
+	trans := newTestGateway(t, replyXml(map[string]string{
+		"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100", "record_num": "1",
+		"fbatchno_0": "B1", "date_settlement_0": "20240102", "settlementfee_type_0": "JPY",
+		"settlement_fee_0": "9800", "pay_fee_0": "10000", "refund_fee_0": "100", "pay_net_fee_0": "9900", "poundage_fee_0": "100",
+	}, true))
+
+	result, err := trans.QuerySettlement(true, "20240101", "20240102", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 1 {
+		t.Fatalf("got %d records", len(result.Records))
+	}
+	record := result.Records[0]
+	if record.SettlementFee != (CurrencyAmount{Amount: 9800, FeeType: "JPY"}) || record.UnsettlementFee.Amount != 0 {
+		t.Errorf("unexpected record: %+v", record)
+	}
+}
+
+func TestQueryExchangeRate(t *testing.T) {
+	trans := newTestGateway(t, replyXml(map[string]string{
+		"return_code": "SUCCESS", "result_code": "SUCCESS", "appid": "wx1", "mch_id": "100",
+		"rate_time": "20240102", "rate": "646055000",
+	}, true))
+
+	rate, err := trans.QueryExchangeRate("USD", "20240102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen, err := rate.ToCny(CurrencyAmount{Amount: 100, FeeType: "USD"}); err != nil || fen != 646 {
+		t.Errorf("1 USD converted to %d, %v", fen, err)
+	}
+	if _, err := rate.ToCny(CurrencyAmount{Amount: 100, FeeType: "JPY"}); err == nil {
+		t.Error("want error converting with rate of other currency")
+	}
+}
@@ -0,0 +1,165 @@
+package wxpay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SignError is returned when the sign of response or notification does not match.
+// Diagnosis is only filled when WxConfig.DiagnoseSign is set.
+type SignError struct {
+	// Want and Got is the expected and received sign, Want is empty for notifications
+	// so the expected sign is never replied to the sender
+	Want string
+	Got  string
+
+	Diagnosis *SignDiagnosis
+}
+
+func (this *SignError) Error() string {
+	if this.Want == "" {
+		return "sign not match"
+	}
+	return fmt.Sprintf("sign not match, want:%s, got:%s", this.Want, this.Got)
+}
+
+// SignDiagnosis explain how a sign is computed, to find out why it does not match.
+// The key is masked, but field values are included, do not expose it to the other side.
+type SignDiagnosis struct {
+	SignType string
+	// Fields is the signed fields in order, Excluded is the empty fields left out
+	Fields   []string
+	Excluded []string
+	// StringToSign is the string hashed, with the key masked
+	StringToSign string
+	WantSign     string
+	GotSign      string
+	// Causes list the suspected causes of mismatch, it may be empty if nothing is found
+	Causes []string
+}
+
+// String format the diagnosis for logging
+func (this *SignDiagnosis) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "sign type: %s\n", this.SignType)
+	fmt.Fprintf(&b, "fields: %s\n", strings.Join(this.Fields, ","))
+	fmt.Fprintf(&b, "excluded empty fields: %s\n", strings.Join(this.Excluded, ","))
+	fmt.Fprintf(&b, "string to sign: %s\n", this.StringToSign)
+	fmt.Fprintf(&b, "want sign: %s, got sign: %s\n", this.WantSign, this.GotSign)
+	for _, cause := range this.Causes {
+		fmt.Fprintf(&b, "suspected cause: %s\n", cause)
+	}
+	return b.String()
+}
+
+// DiagnoseSign compute the sign of param as SignWithType does and report the details,
+// comparing with the "sign" field of param
+func DiagnoseSign(param map[string]string, key string, signType string) *SignDiagnosis {
+	if signType == "" {
+		signType = SignTypeMD5
+	}
+
+	diag := &SignDiagnosis{SignType: signType, GotSign: param["sign"]}
+	signed := make(map[string]string)
+	for k, v := range param {
+		if k == "sign" {
+			continue
+		}
+		if v == "" {
+			diag.Excluded = append(diag.Excluded, k)
+			continue
+		}
+		signed[k] = v
+		diag.Fields = append(diag.Fields, k)
+	}
+	sort.Strings(diag.Fields)
+	sort.Strings(diag.Excluded)
+
+	diag.StringToSign = SortAndConcat(signed) + "&key=" + strings.Repeat("*", len(key))
+	diag.WantSign = SignWithType(param, key, signType)
+	gotSign := strings.ToUpper(diag.GotSign)
+	if gotSign == diag.WantSign {
+		return diag
+	}
+
+	if gotSign == "" {
+		diag.Causes = append(diag.Causes, "sign is missing")
+		return diag
+	}
+	if len(key) != 32 {
+		diag.Causes = append(diag.Causes, fmt.Sprintf("api key has %d characters, it should be 32", len(key)))
+	}
+	if declared := param["sign_type"]; declared != "" && declared != signType {
+		diag.Causes = append(diag.Causes, fmt.Sprintf("sign_type field is %s but verified with %s", declared, signType))
+	}
+	otherType := SignTypeHmacSha256
+	if signType == SignTypeHmacSha256 {
+		otherType = SignTypeMD5
+	}
+	if gotSign == SignWithType(param, key, otherType) {
+		diag.Causes = append(diag.Causes, fmt.Sprintf("sign is computed with %s", otherType))
+	}
+	if len(diag.Excluded) > 0 && gotSign == signIncludingEmpty(param, key, signType) {
+		diag.Causes = append(diag.Causes, "empty fields are included when signing: "+strings.Join(diag.Excluded, ","))
+	}
+	for _, k := range diag.Fields {
+		if v := param[k]; strings.TrimSpace(v) != v {
+			diag.Causes = append(diag.Causes, "value of "+k+" has leading or trailing whitespace")
+		}
+	}
+
+	return diag
+}
+
+// signIncludingEmpty sign the param like SignWithType but keep empty fields, a common mistake
+func signIncludingEmpty(param map[string]string, key string, signType string) string {
+	return hashSign(SortAndConcat(withoutSign(param))+"&key="+key, key, signType)
+}
+
+func withoutSign(param map[string]string) map[string]string {
+	newMap := make(map[string]string)
+	for k, v := range param {
+		if k != "sign" {
+			newMap[k] = v
+		}
+	}
+	return newMap
+}
+
+const (
+	// SignSourceNotify is the source of SignFailure for payment notifications
+	SignSourceNotify = "notify"
+	// SignSourceNativeCallback is the source of SignFailure for callbacks of Native mode 1
+	SignSourceNativeCallback = "native_callback"
+)
+
+// SignFailure describe a failed sign verification reported to WxConfig.OnSignFailure
+type SignFailure struct {
+	// Source is the api path of the response(e.g. PathRefund), or SignSourceNotify/SignSourceNativeCallback
+	Source string
+	// AppId and MchId is from the message, they may differ from config for forged messages
+	AppId string
+	MchId string
+	Err   error
+}
+
+// reportSignFailure call WxConfig.OnSignFailure with the failure and return err
+func (this *AppTrans) reportSignFailure(source string, param map[string]string, err error) error {
+	if this.Config.OnSignFailure != nil {
+		this.Config.OnSignFailure(SignFailure{Source: source, AppId: param["appid"], MchId: param["mch_id"], Err: err})
+	}
+	return err
+}
+
+// signError build the *SignError of mismatched sign, with diagnosis if it is enabled
+func (this *AppTrans) signError(param map[string]string, key string, signType string, showWant bool) error {
+	err := &SignError{Got: param["sign"]}
+	if showWant {
+		err.Want = SignWithType(param, key, signType)
+	}
+	if this.Config.DiagnoseSign {
+		err.Diagnosis = DiagnoseSign(param, key, signType)
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package wxpay
+
+import (
+	"fmt"
+)
+
+// TradeType is the trade_type of order, it decides the parameters required by order,
+// the fields returned by place order and how the payment is started
+type TradeType string
+
+const (
+	TradeTypeApp      TradeType = "APP"
+	TradeTypeJsApi    TradeType = "JSAPI"
+	TradeTypeNative   TradeType = "NATIVE"
+	TradeTypeMweb     TradeType = "MWEB"
+	TradeTypeMicropay TradeType = "MICROPAY"
+)
+
+// Valid tell whether the trade type is known
+func (t TradeType) Valid() bool {
+	switch t {
+	case TradeTypeApp, TradeTypeJsApi, TradeTypeNative, TradeTypeMweb, TradeTypeMicropay:
+		return true
+	}
+	return false
+}
+
+// requiredParams list the order parameters the trade type cannot go without
+func (t TradeType) requiredParams() []string {
+	switch t {
+	case TradeTypeJsApi:
+		return []string{"openid"}
+	case TradeTypeNative:
+		return []string{"product_id"}
+	case TradeTypeMweb:
+		return []string{"scene_info"}
+	}
+	return nil
+}
+
+// resultFields list the fields expected in a successful place order result of the trade type
+func (t TradeType) resultFields() []string {
+	switch t {
+	case TradeTypeNative:
+		return []string{"prepay_id", "code_url"}
+	case TradeTypeMweb:
+		return []string{"prepay_id", "mweb_url"}
+	}
+	return []string{"prepay_id"}
+}
+
+// checkPlaceOrderResult check the fields expected by the trade type are in the result
+func (t TradeType) checkPlaceOrderResult(result *PlaceOrderResult) error {
+	resultInMap := result.ToMap()
+	for _, key := range t.resultFields() {
+		if resultInMap[key] == "" {
+			return fmt.Errorf("%s is missing in place order result of trade type %s", key, t)
+		}
+	}
+	return nil
+}
+
+// NewPaymentParams build what the client need to start the payment of the placed order according to
+// the trade type in config: PaymentRequest for APP, JsApiPaymentRequest for JSAPI, the code_url
+// string for NATIVE and the mweb_url string for MWEB.
+func (this *AppTrans) NewPaymentParams(result *PlaceOrderResult) (interface{}, error) {
+	switch this.Config.TradeType {
+	case TradeTypeApp:
+		return this.NewAppPaymentRequest(result.PrepayId)
+	case TradeTypeJsApi:
+		return this.NewJsApiPaymentRequest(result.PrepayId)
+	case TradeTypeNative:
+		return result.CodeUrl, nil
+	case TradeTypeMweb:
+		return result.MwebUrl, nil
+	}
+	return nil, fmt.Errorf("no payment parameters for trade type %s", this.Config.TradeType)
+}
@@ -0,0 +1,34 @@
+package wxpay
+
+import (
+	"context"
+)
+
+// Transactor is the payment operations of AppTrans, depend on it instead of *AppTrans
+// so the payment can be mocked in unit tests of the service
+type Transactor interface {
+	Submit(params map[string]string) (*PlaceOrderResult, error)
+	SubmitOrder(req *OrderRequest) (*PlaceOrderResult, error)
+	Query(transId string) (QueryOrderResult, error)
+	QueryByOutTradeNo(outTradeNo string) (QueryOrderResult, error)
+	CloseOrder(outTradeNo string) (*CloseOrderResult, error)
+	Micropay(params map[string]string) (*MicropayResult, error)
+	Reverse(outTradeNo string) (*ReverseResult, error)
+	Refund(params map[string]string) (*RefundResult, error)
+	RefundOrder(req *RefundRequest) (*RefundResult, error)
+	RefundOnce(req *RefundRequest, seq int) (*RefundResult, error)
+	QueryRefund(query *RefundQuery) (*RefundQueryResult, error)
+	Transfer(req *TransferRequest) (*TransferResult, error)
+	PayBank(req *PayBankRequest) (*PayBankResult, error)
+	SendRedPack(req *RedPackRequest) (*RedPackResult, error)
+	SendGroupRedPack(req *RedPackRequest) (*RedPackResult, error)
+	ProfitSharing(transactionId, outOrderNo string, receivers []ProfitSharingReceiver) (*ProfitSharingResult, error)
+	MultiProfitSharing(transactionId, outOrderNo string, receivers []ProfitSharingReceiver) (*ProfitSharingResult, error)
+	FinishProfitSharing(transactionId, outOrderNo, description string) (*ProfitSharingResult, error)
+	ConfirmPayment(ctx context.Context, outTradeNo string, totalFee int64) (bool, error)
+	NewPaymentRequest(prepayId string) PaymentRequest
+	NewAppPaymentRequest(prepayId string) (PaymentRequest, error)
+	NewJsApiPaymentRequest(prepayId string) (JsApiPaymentRequest, error)
+}
+
+var _ Transactor = (*AppTrans)(nil)
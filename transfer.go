@@ -0,0 +1,134 @@
+package wxpay
+
+import (
+	"context"
+	"encoding/xml"
+)
+
+const (
+	// CheckNameNone do not check the real name of user
+	CheckNameNone = "NO_CHECK"
+	// CheckNameForce check the real name of user with ReUserName, the transfer fails if it does not match
+	CheckNameForce = "FORCE_CHECK"
+)
+
+// TransferRequest is the parameter of transfer to user balance(企业付款到零钱).
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/tools/mch_pay.php?chapter=14_2
+type TransferRequest struct {
+	PartnerTradeNo string
+	OpenId         string
+	// CheckName is CheckNameNone or CheckNameForce
+	CheckName  string
+	ReUserName string
+	// Amount is in fen
+	Amount         int64
+	Desc           string
+	SpbillCreateIp string
+	DeviceInfo     string
+	// Extra is merged into the signed parameters, it cannot override the typed fields
+	Extra map[string]string
+}
+
+// TransferResult represent the response of transfer, note the field names mch_appid and mchid
+type TransferResult struct {
+	XMLName        xml.Name `xml:"xml"`
+	ReturnCode     string   `xml:"return_code"`
+	ReturnMsg      string   `xml:"return_msg"`
+	MchAppId       string   `xml:"mch_appid"`
+	MchId          string   `xml:"mchid"`
+	DeviceInfo     string   `xml:"device_info"`
+	NonceStr       string   `xml:"nonce_str"`
+	ResultCode     string   `xml:"result_code"`
+	ErrCode        string   `xml:"err_code"`
+	ErrCodeDesc    string   `xml:"err_code_des"`
+	PartnerTradeNo string   `xml:"partner_trade_no"`
+	PaymentNo      string   `xml:"payment_no"`
+	PaymentTime    string   `xml:"payment_time"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// Transfer pay to the balance of user from the merchant account. The api name appid and mch_id as
+// mch_appid and mchid, and only accept MD5 sign. Merchant certificate is required.
+// On SYSTEMERROR the result is unknown, query it with QueryTransfer and retry with the same
+// PartnerTradeNo, a new one may pay twice.
+func (this *AppTrans) Transfer(req *TransferRequest) (*TransferResult, error) {
+	amount, err := formatFee("amount", req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	param := make(map[string]string)
+	param["mch_appid"] = this.Config.AppId
+	param["mchid"] = this.Config.MchId
+	setIfNotEmpty(param, "device_info", req.DeviceInfo)
+	param["partner_trade_no"] = req.PartnerTradeNo
+	param["openid"] = req.OpenId
+	param["check_name"] = req.CheckName
+	setIfNotEmpty(param, "re_user_name", req.ReUserName)
+	param["amount"] = amount
+	param["desc"] = req.Desc
+	setIfNotEmpty(param, "spbill_create_ip", req.SpbillCreateIp)
+	mergeExtra(param, req.Extra)
+
+	resp, respInMap, envelope, err := this.call(context.Background(), Endpoints[PathTransfers], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	result := TransferResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TransferInfo represent the response of transfer query
+type TransferInfo struct {
+	XMLName        xml.Name `xml:"xml"`
+	ReturnCode     string   `xml:"return_code"`
+	ReturnMsg      string   `xml:"return_msg"`
+	ResultCode     string   `xml:"result_code"`
+	ErrCode        string   `xml:"err_code"`
+	ErrCodeDesc    string   `xml:"err_code_des"`
+	AppId          string   `xml:"appid"`
+	MchId          string   `xml:"mch_id"`
+	PartnerTradeNo string   `xml:"partner_trade_no"`
+	DetailId       string   `xml:"detail_id"`
+	// Status is SUCCESS, FAILED or PROCESSING
+	Status        string `xml:"status"`
+	Reason        string `xml:"reason"`
+	OpenId        string `xml:"openid"`
+	TransferName  string `xml:"transfer_name"`
+	PaymentAmount string `xml:"payment_amount"`
+	TransferTime  string `xml:"transfer_time"`
+	PaymentTime   string `xml:"payment_time"`
+	Desc          string `xml:"desc"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// QueryTransfer query the transfer by partner_trade_no, e.g. to confirm the result after SYSTEMERROR
+// before retrying. Transfers within 30 days can be queried. Merchant certificate is required.
+// Refer to https://pay.weixin.qq.com/wiki/doc/api/tools/mch_pay.php?chapter=14_3
+func (this *AppTrans) QueryTransfer(partnerTradeNo string) (*TransferInfo, error) {
+	param := this.newParam()
+	param["partner_trade_no"] = partnerTradeNo
+
+	resp, respInMap, envelope, err := this.call(context.Background(), Endpoints[PathGetTransferInfo], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	result := TransferInfo{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -1,7 +1,10 @@
 package wxpay
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
 	"fmt"
 	"sort"
 	"strconv"
@@ -27,12 +30,22 @@ func SortAndConcat(param map[string]string) string {
 	return strings.Join(sortedParam, "&")
 }
 
+const (
+	SignTypeMD5        = "MD5"
+	SignTypeHmacSha256 = "HMAC-SHA256"
+)
+
 // Sign the parameter in form of map[string]string with app key.
 // Empty string and "sign" key is excluded before sign.
 // Please refer to http://pay.weixin.qq.com/wiki/doc/api/app.php?chapter=4_3
 func Sign(param map[string]string, key string) string {
+	return SignWithType(param, key, SignTypeMD5)
+}
+
+// SignWithType is like Sign but use the specific sign type, SignTypeMD5 or SignTypeHmacSha256.
+// Empty sign type is treated as SignTypeMD5.
+func SignWithType(param map[string]string, key string, signType string) string {
 	newMap := make(map[string]string)
-	// fmt.Printf("%#v\n", param)
 	for k, v := range param {
 		if k == "sign" {
 			continue
@@ -42,15 +55,38 @@ func Sign(param map[string]string, key string) string {
 		}
 		newMap[k] = v
 	}
-	// fmt.Printf("%#v\n\n", newMap)
 
 	preSignStr := SortAndConcat(newMap)
-	preSignWithKey := preSignStr + "&key=" + key
-	fmt.Println(preSignWithKey)
+	return hashSign(preSignStr+"&key="+key, key, signType)
+}
 
+// hashSign hash the string to sign with the sign type
+func hashSign(preSignWithKey string, key string, signType string) string {
+	if signType == SignTypeHmacSha256 {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(preSignWithKey))
+		return fmt.Sprintf("%X", mac.Sum(nil))
+	}
 	return fmt.Sprintf("%X", md5.Sum([]byte(preSignWithKey)))
 }
 
+// VerifySign verify the "sign" field of the parameter (e.g. a notification) with app key.
+// Sign is compared case insensitively in constant time.
+func VerifySign(param map[string]string, key string) bool {
+	return VerifySignWithType(param, key, SignTypeMD5)
+}
+
+// VerifySignWithType is like VerifySign but use the specific sign type
+func VerifySignWithType(param map[string]string, key string, signType string) bool {
+	gotSign := strings.ToUpper(param["sign"])
+	if gotSign == "" {
+		return false
+	}
+
+	wantSign := SignWithType(param, key, signType)
+	return subtle.ConstantTimeCompare([]byte(wantSign), []byte(gotSign)) == 1
+}
+
 // NewNonceString return random string in 32 characters
 func NewNonceString() string {
 	nonce := strconv.FormatInt(time.Now().UnixNano(), 36)
@@ -59,7 +95,7 @@ func NewNonceString() string {
 
 const ChinaTimeZoneOffset = 8 * 60 * 60 //Beijing(UTC+8:00)
 
-// NewTimestampString return
+// NewTimestampString return the current unix timestamp in seconds, which is independent of time zone
 func NewTimestampString() string {
-	return fmt.Sprintf("%d", time.Now().Unix()+ChinaTimeZoneOffset)
+	return fmt.Sprintf("%d", time.Now().Unix())
 }
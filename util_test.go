@@ -0,0 +1,59 @@
+package wxpay
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// the example of sign in https://pay.weixin.qq.com/wiki/doc/api/app/app.php?chapter=4_3
+var signExample = map[string]string{
+	"appid":       "wxd930ea5d5a258f4f",
+	"mch_id":      "10000100",
+	"device_info": "1000",
+	"body":        "test",
+	"nonce_str":   "ibuaiVcKdpRxkhJA",
+}
+
+const signExampleKey = "192006250b4c09247ec02edce69f6a2d"
+
+func TestSignMD5(t *testing.T) {
+	if sign := Sign(signExample, signExampleKey); sign != "9A0A8659F005D6984697E2CA0A9CF3B7" {
+		t.Errorf("got %s", sign)
+	}
+}
+
+func TestSignHmacSha256(t *testing.T) {
+	sign := SignWithType(signExample, signExampleKey, SignTypeHmacSha256)
+	if sign != "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6" {
+		t.Errorf("got %s", sign)
+	}
+}
+
+func TestVerifySignWithType(t *testing.T) {
+	for _, signType := range []string{SignTypeMD5, SignTypeHmacSha256} {
+		param := map[string]string{"body": "a&b 😀", "empty": ""}
+		for k, v := range signExample {
+			param[k] = v
+		}
+		param["sign"] = SignWithType(param, signExampleKey, signType)
+
+		if !VerifySignWithType(param, signExampleKey, signType) {
+			t.Errorf("%s: sign not verified", signType)
+		}
+		param["body"] = "tampered"
+		if VerifySignWithType(param, signExampleKey, signType) {
+			t.Errorf("%s: tampered param verified", signType)
+		}
+	}
+}
+
+func TestNewTimestampString(t *testing.T) {
+	timestamp, err := strconv.ParseInt(NewTimestampString(), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := time.Now().Unix() - timestamp; diff < -1 || diff > 1 {
+		t.Errorf("timestamp is off by %d seconds", diff)
+	}
+}
@@ -0,0 +1,176 @@
+package wxpay
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+)
+
+// WorkWxTransferRequest is the parameter of paying employee of WeChat Work(向员工付款).
+// Refer to https://work.weixin.qq.com/api/doc/90000/90135/90278
+type WorkWxTransferRequest struct {
+	PartnerTradeNo string
+	OpenId         string
+	// CheckName is NO_CHECK or FORCE_CHECK, ReUserName is required for FORCE_CHECK
+	CheckName      string
+	ReUserName     string
+	Amount         int64
+	Desc           string
+	SpbillCreateIp string
+	// WwMsgType is NORMAL_MSG or APPROVAL_MSG, ApprovalNumber and ApprovalType is required for APPROVAL_MSG
+	WwMsgType      string
+	ApprovalNumber string
+	ApprovalType   string
+	ActName        string
+	AgentId        string
+	// Extra is merged into the signed parameters, it cannot override the typed fields
+	Extra map[string]string
+}
+
+// WorkWxTransferResult represent the response of paying employee
+type WorkWxTransferResult struct {
+	XMLName        xml.Name `xml:"xml"`
+	ReturnCode     string   `xml:"return_code"`
+	ReturnMsg      string   `xml:"return_msg"`
+	AppId          string   `xml:"appid"`
+	MchId          string   `xml:"mch_id"`
+	DeviceInfo     string   `xml:"device_info"`
+	NonceStr       string   `xml:"nonce_str"`
+	ResultCode     string   `xml:"result_code"`
+	ErrCode        string   `xml:"err_code"`
+	ErrCodeDesc    string   `xml:"err_code_des"`
+	PartnerTradeNo string   `xml:"partner_trade_no"`
+	PaymentNo      string   `xml:"payment_no"`
+	PaymentTime    string   `xml:"payment_time"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// PayEmployee pay to the balance of employee via WeChat Work, secret is the secret of
+// the payment application in WeChat Work used for workwx_sign. Merchant certificate is required.
+func (this *AppTrans) PayEmployee(req *WorkWxTransferRequest, secret string) (*WorkWxTransferResult, error) {
+	amount, err := formatFee("amount", req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	param := this.newParam()
+	param["nonce_str"] = NewNonceString()
+	param["partner_trade_no"] = req.PartnerTradeNo
+	param["openid"] = req.OpenId
+	param["check_name"] = req.CheckName
+	setIfNotEmpty(param, "re_user_name", req.ReUserName)
+	param["amount"] = amount
+	param["desc"] = req.Desc
+	param["spbill_create_ip"] = req.SpbillCreateIp
+	param["ww_msg_type"] = req.WwMsgType
+	setIfNotEmpty(param, "approval_number", req.ApprovalNumber)
+	setIfNotEmpty(param, "approval_type", req.ApprovalType)
+	param["act_name"] = req.ActName
+	setIfNotEmpty(param, "agentid", req.AgentId)
+	mergeExtra(param, req.Extra)
+	param["workwx_sign"] = WorkWxSign(param, []string{"amount", "appid", "desc", "mch_id", "nonce_str",
+		"openid", "partner_trade_no", "ww_msg_type"}, secret)
+
+	resp, respInMap, envelope, err := this.call(context.Background(), Endpoints[PathWorkWxTransfer], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	result := WorkWxTransferResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WorkWxRedPackRequest is the parameter of sending red packet of WeChat Work(企业微信红包).
+// Refer to https://work.weixin.qq.com/api/doc/90000/90135/90275
+type WorkWxRedPackRequest struct {
+	MchBillNo           string
+	SenderName          string
+	SenderHeaderMediaId string
+	ReOpenId            string
+	TotalAmount         int64
+	Wishing             string
+	ActName             string
+	Remark              string
+	SceneId             string
+	// Extra is merged into the signed parameters, it cannot override the typed fields
+	Extra map[string]string
+}
+
+// WorkWxRedPackResult represent the response of sending red packet of WeChat Work
+type WorkWxRedPackResult struct {
+	XMLName             xml.Name `xml:"xml"`
+	ReturnCode          string   `xml:"return_code"`
+	ReturnMsg           string   `xml:"return_msg"`
+	ResultCode          string   `xml:"result_code"`
+	ErrCode             string   `xml:"err_code"`
+	ErrCodeDesc         string   `xml:"err_code_des"`
+	MchBillNo           string   `xml:"mch_billno"`
+	MchId               string   `xml:"mch_id"`
+	WxAppId             string   `xml:"wxappid"`
+	ReOpenId            string   `xml:"re_openid"`
+	TotalAmount         string   `xml:"total_amount"`
+	SendListId          string   `xml:"send_listid"`
+	SenderName          string   `xml:"sender_name"`
+	SenderHeaderMediaId string   `xml:"sender_header_media_id"`
+	// Envelope is the transport level details of the response
+	Envelope *Envelope `xml:"-"`
+}
+
+// SendWorkWxRedPack send red packet to employee via WeChat Work, secret is the secret of
+// the payment application in WeChat Work used for workwx_sign. Merchant certificate is required.
+func (this *AppTrans) SendWorkWxRedPack(req *WorkWxRedPackRequest, secret string) (*WorkWxRedPackResult, error) {
+	totalAmount, err := formatFee("total_amount", req.TotalAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	param := make(map[string]string)
+	param["wxappid"] = this.Config.AppId
+	param["mch_id"] = this.Config.MchId
+	param["nonce_str"] = NewNonceString()
+	param["mch_billno"] = req.MchBillNo
+	setIfNotEmpty(param, "sender_name", req.SenderName)
+	setIfNotEmpty(param, "sender_header_media_id", req.SenderHeaderMediaId)
+	param["re_openid"] = req.ReOpenId
+	param["total_amount"] = totalAmount
+	param["wishing"] = req.Wishing
+	param["act_name"] = req.ActName
+	param["remark"] = req.Remark
+	setIfNotEmpty(param, "scene_id", req.SceneId)
+	mergeExtra(param, req.Extra)
+	param["workwx_sign"] = WorkWxSign(param, []string{"act_name", "mch_billno", "mch_id", "nonce_str",
+		"re_openid", "total_amount", "wxappid"}, secret)
+
+	resp, respInMap, envelope, err := this.call(context.Background(), Endpoints[PathWorkWxRedPack], param)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultError(respInMap, envelope); err != nil {
+		return nil, err
+	}
+
+	result := WorkWxRedPackResult{Envelope: envelope}
+	if err := this.unmarshalXml(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WorkWxSign compute workwx_sign over the given keys of param with the secret of WeChat Work payment application
+func WorkWxSign(param map[string]string, keys []string, secret string) string {
+	signParam := make(map[string]string)
+	for _, k := range keys {
+		signParam[k] = param[k]
+	}
+
+	preSignWithSecret := SortAndConcat(signParam) + "&secret=" + secret
+	return fmt.Sprintf("%X", md5.Sum([]byte(preSignWithSecret)))
+}
@@ -1,19 +1,125 @@
 package wxpay
 
 import (
+	"bytes"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"reflect"
+	"sort"
 )
 
-// ToXmlString convert the map[string]string to xml string
+// CharsetReader convert non UTF-8 content (e.g. GBK) from weixin pay to UTF-8.
+// Standard library has no GBK support, set it to golang.org/x/net/html/charset.NewReaderLabel
+// or similar when legacy responses declare encoding="GBK" in the xml header.
+// It is used by the package level parsers and by AppTrans whose WxConfig.CharsetReader is not set.
+var CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+// unmarshalXml is like xml.Unmarshal but honors the encoding declared in xml header with CharsetReader
+func unmarshalXml(data []byte, v interface{}) error {
+	return unmarshalXmlWith(data, v, CharsetReader)
+}
+
+// unmarshalXmlWith is like unmarshalXml with the specific charset reader
+func unmarshalXmlWith(data []byte, v interface{}, charsetReader func(string, io.Reader) (io.Reader, error)) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charsetReaderOrError(charsetReader)
+	return decoder.Decode(v)
+}
+
+// charsetReaderOrError return charsetReader, or one failing with a hint of the config if it is nil
+func charsetReaderOrError(charsetReader func(string, io.Reader) (io.Reader, error)) func(string, io.Reader) (io.Reader, error) {
+	if charsetReader != nil {
+		return charsetReader
+	}
+	return func(charset string, input io.Reader) (io.Reader, error) {
+		return nil, fmt.Errorf("unsupported charset:%s, set WxConfig.CharsetReader to convert it", charset)
+	}
+}
+
+// charsetReader return WxConfig.CharsetReader, or the package level CharsetReader if it is not set
+func (this *AppTrans) charsetReader() func(string, io.Reader) (io.Reader, error) {
+	if this.Config.CharsetReader != nil {
+		return this.Config.CharsetReader
+	}
+	return CharsetReader
+}
+
+// unmarshalXml is like unmarshalXml of package with the charset reader of config
+func (this *AppTrans) unmarshalXml(data []byte, v interface{}) error {
+	return unmarshalXmlWith(data, v, this.charsetReader())
+}
+
+// parseXmlMap is like ParseXmlMap with the charset reader of config
+func (this *AppTrans) parseXmlMap(data []byte) (map[string]string, error) {
+	return parseXmlMapWith(data, this.charsetReader())
+}
+
+// ToXmlString convert the map[string]string to xml string.
+// Values are escaped, so characters like '&' and '<' in body or attach are sent as is.
 func ToXmlString(param map[string]string) string {
-	xml := "<xml>"
-	for k, v := range param {
-		xml = xml + fmt.Sprintf("<%s>%s</%s>", k, v, k)
+	var keys []string
+	for k := range param {
+		keys = append(keys, k)
 	}
-	xml = xml + "</xml>"
+	sort.Strings(keys)
 
-	return xml
+	var buf bytes.Buffer
+	buf.WriteString("<xml>")
+	for _, k := range keys {
+		buf.WriteString("<" + k + ">")
+		xml.EscapeText(&buf, []byte(param[k]))
+		buf.WriteString("</" + k + ">")
+	}
+	buf.WriteString("</xml>")
+
+	return buf.String()
+}
+
+// ParseXmlMap parse the flat xml message like "<xml><k1>v1</k1><k2>v2</k2></xml>" to map[string]string.
+// Unlike the typed parsers, all fields in the message is kept, which is needed for verifying sign.
+func ParseXmlMap(data []byte) (map[string]string, error) {
+	return parseXmlMapWith(data, CharsetReader)
+}
+
+// parseXmlMapWith is like ParseXmlMap with the specific charset reader
+func parseXmlMapWith(data []byte, charsetReader func(string, io.Reader) (io.Reader, error)) (map[string]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charsetReaderOrError(charsetReader)
+
+	out := make(map[string]string)
+	depth := 0
+	key := ""
+	var value bytes.Buffer
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				key = t.Name.Local
+				value.Reset()
+			}
+		case xml.CharData:
+			if depth == 2 {
+				value.Write(t)
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				out[key] = value.String()
+			}
+			depth--
+		}
+	}
+
+	return out, nil
 }
 
 // ToMap convert the xml struct to map[string]string
@@ -34,7 +140,7 @@ func ToMap(in interface{}) (map[string]string, error) {
 	for i := 0; i < v.NumField(); i++ {
 		// gets us a StructField
 		fi := typ.Field(i)
-		if tagv := fi.Tag.Get("xml"); tagv != "" && tagv != "xml" {
+		if tagv := fi.Tag.Get("xml"); tagv != "" && tagv != "xml" && tagv != "-" {
 			// set key of map to value in struct field
 			out[tagv] = v.Field(i).String()
 		}
@@ -0,0 +1,65 @@
+package wxpay
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestToXmlStringRoundTrip(t *testing.T) {
+	param := map[string]string{
+		"body":   `Tom & Jerry <"零食"> 'x'`,
+		"attach": "emoji 😀🎉 and 中文",
+		"detail": `{"goods_detail":[{"goods_name":"a<b>&c"}]}`,
+		"empty":  "",
+	}
+
+	parsed, err := ParseXmlMap([]byte(ToXmlString(param)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(param) {
+		t.Fatalf("got %d fields, want %d", len(parsed), len(param))
+	}
+	for k, v := range param {
+		if parsed[k] != v {
+			t.Errorf("%s: got %q, want %q", k, parsed[k], v)
+		}
+	}
+}
+
+func TestToXmlStringEscapes(t *testing.T) {
+	xmlString := ToXmlString(map[string]string{"body": "a&b<c>"})
+	if want := "<xml><body>a&amp;b&lt;c&gt;</body></xml>"; xmlString != want {
+		t.Errorf("got %s, want %s", xmlString, want)
+	}
+}
+
+func TestParseXmlMapCData(t *testing.T) {
+	data := `<xml><return_code><![CDATA[SUCCESS]]></return_code><body><![CDATA[a&b<c> 😀]]></body><total_fee>1</total_fee></xml>`
+	parsed, err := ParseXmlMap([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed["return_code"] != "SUCCESS" || parsed["body"] != "a&b<c> 😀" || parsed["total_fee"] != "1" {
+		t.Errorf("unexpected result: %v", parsed)
+	}
+}
+
+func TestParseXmlMapCharset(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="GBK"?><xml><a>1</a></xml>`)
+	if _, err := ParseXmlMap(data); err == nil || !strings.Contains(err.Error(), "CharsetReader") {
+		t.Errorf("want error about CharsetReader, got %v", err)
+	}
+
+	trans := &AppTrans{Config: &WxConfig{CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}}}
+	parsed, err := trans.parseXmlMap(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed["a"] != "1" {
+		t.Errorf("unexpected result: %v", parsed)
+	}
+}